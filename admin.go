@@ -0,0 +1,281 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const (
+	adminModeDrain       = "drain"
+	adminModeMaintenance = "maintenance"
+	adminModeShutdown    = "shutdown" // Set on SIGTERM, never persisted
+)
+
+// AdminState describes an operator-requested mode forcing the probe to report KO
+type AdminState struct {
+	Mode    string     `json:"mode"`
+	Reason  string     `json:"reason,omitempty"`
+	Since   time.Time  `json:"since"`
+	Expires *time.Time `json:"expires,omitempty"`
+}
+
+// adminRequest is the optional JSON body of admin mode requests
+type adminRequest struct {
+	Reason   string `json:"reason"`
+	Duration string `json:"duration"`
+}
+
+var (
+	adminState    *AdminState
+	shutdownState *AdminState // Takes precedence over adminState once shutdown starts
+	adminMutex    sync.Mutex
+)
+
+// currentAdminState returns the active admin mode, or nil when none is set.
+// Expired modes are cleared.
+func currentAdminState() *AdminState {
+	adminMutex.Lock()
+	defer adminMutex.Unlock()
+
+	if shutdownState != nil {
+		state := *shutdownState
+		return &state
+	}
+
+	if adminState == nil {
+		return nil
+	}
+
+	if adminState.Expires != nil && time.Now().After(*adminState.Expires) {
+		logInfo("Admin mode %s expired", adminState.Mode)
+		adminState = nil
+		if err := saveAdminState(currentConfig().Admin.StateFile, nil); err != nil {
+			logError("Failed to persist admin state: %v", err)
+		}
+		return nil
+	}
+
+	state := *adminState
+	return &state
+}
+
+// setAdminState replaces the active admin mode and persists it; nil clears it
+func setAdminState(state *AdminState) error {
+	adminMutex.Lock()
+	defer adminMutex.Unlock()
+
+	adminState = state
+	return saveAdminState(currentConfig().Admin.StateFile, state)
+}
+
+// beginShutdown switches the probe to the shutdown mode, which reports KO
+// like drain mode until the process exits
+func beginShutdown(reason string) {
+	adminMutex.Lock()
+	defer adminMutex.Unlock()
+
+	shutdownState = &AdminState{
+		Mode:   adminModeShutdown,
+		Reason: reason,
+		Since:  time.Now(),
+	}
+}
+
+// saveAdminState writes state to filename, removing the file when state is nil
+func saveAdminState(filename string, state *AdminState) error {
+	if filename == "" {
+		return nil
+	}
+
+	if state == nil {
+		if err := os.Remove(filename); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("failed to remove state file: %w", err)
+		}
+		return nil
+	}
+
+	data, err := json.Marshal(state)
+	if err != nil {
+		return fmt.Errorf("failed to marshal admin state: %w", err)
+	}
+
+	// Write to a temporary file first so a crash never leaves a truncated state
+	tmpFile := filename + ".tmp"
+	if err := os.WriteFile(tmpFile, data, 0600); err != nil {
+		return fmt.Errorf("failed to write state file: %w", err)
+	}
+	if err := os.Rename(tmpFile, filename); err != nil {
+		return fmt.Errorf("failed to write state file: %w", err)
+	}
+
+	return nil
+}
+
+// checkStateDir verifies that the directory of the state file filename is writable
+func checkStateDir(filename string) error {
+	file, err := os.CreateTemp(filepath.Dir(filename), ".probe-state-*")
+	if err != nil {
+		return fmt.Errorf("directory not writable: %w", err)
+	}
+	file.Close()
+	return os.Remove(file.Name())
+}
+
+// loadAdminState restores the admin mode persisted in filename, if any
+func loadAdminState(filename string) error {
+	if filename == "" {
+		return nil
+	}
+
+	data, err := os.ReadFile(filename)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read state file: %w", err)
+	}
+
+	var state AdminState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return fmt.Errorf("failed to parse state file: %w", err)
+	}
+
+	adminMutex.Lock()
+	adminState = &state
+	adminMutex.Unlock()
+
+	logInfo("Restored admin mode %s from %s", state.Mode, filename)
+	return nil
+}
+
+// adminModeHandler returns a handler that puts the probe into the given mode
+func adminModeHandler(mode string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !authorizeAdmin(w, r) {
+			return
+		}
+
+		var req adminRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+			http.Error(w, fmt.Sprintf("invalid request body: %v", err), http.StatusBadRequest)
+			return
+		}
+
+		state := &AdminState{
+			Mode:   mode,
+			Reason: req.Reason,
+			Since:  time.Now(),
+		}
+		if req.Duration != "" {
+			duration, err := time.ParseDuration(req.Duration)
+			if err != nil || duration <= 0 {
+				http.Error(w, fmt.Sprintf("invalid duration: %q", req.Duration), http.StatusBadRequest)
+				return
+			}
+			expires := state.Since.Add(duration)
+			state.Expires = &expires
+		}
+
+		// The mode applies even when it cannot be persisted, but would not survive a restart
+		err := setAdminState(state)
+		logInfo("Admin mode %s enabled from %s (reason: %q)", mode, r.RemoteAddr, req.Reason)
+		if err != nil {
+			logError("Failed to persist admin state: %v", err)
+			http.Error(w, fmt.Sprintf("admin mode %s enabled but not persisted: %v", mode, err), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(state)
+	}
+}
+
+// adminClearHandler handles the /admin/clear endpoint
+func adminClearHandler(w http.ResponseWriter, r *http.Request) {
+	if !authorizeAdmin(w, r) {
+		return
+	}
+
+	err := setAdminState(nil)
+	logInfo("Admin mode cleared from %s", r.RemoteAddr)
+	if err != nil {
+		logError("Failed to persist admin state: %v", err)
+		http.Error(w, fmt.Sprintf("admin mode cleared but not persisted: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// authorizeAdmin checks the method and bearer token of an admin request,
+// writing an error response when the request is rejected
+func authorizeAdmin(w http.ResponseWriter, r *http.Request) bool {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return false
+	}
+
+	token := currentConfig().Admin.Token
+	if token == "" {
+		http.Error(w, "admin endpoints are disabled", http.StatusForbidden)
+		return false
+	}
+
+	provided, found := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !found || subtle.ConstantTimeCompare([]byte(provided), []byte(token)) != 1 {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return false
+	}
+
+	return true
+}
+
+// watchAdminSignals toggles drain mode on SIGUSR1 and maintenance mode on SIGUSR2
+func watchAdminSignals(ctx context.Context) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGUSR1, syscall.SIGUSR2)
+	defer signal.Stop(signals)
+
+	for {
+		var mode string
+		select {
+		case <-ctx.Done():
+			return
+		case sig := <-signals:
+			mode = adminModeDrain
+			if sig == syscall.SIGUSR2 {
+				mode = adminModeMaintenance
+			}
+		}
+
+		// Sending the signal of the active mode again clears it
+		var state *AdminState
+		if current := currentAdminState(); current == nil || current.Mode != mode {
+			state = &AdminState{
+				Mode:   mode,
+				Reason: "signal",
+				Since:  time.Now(),
+			}
+			logInfo("Admin mode %s enabled by signal", mode)
+		} else {
+			logInfo("Admin mode %s cleared by signal", mode)
+		}
+
+		if err := setAdminState(state); err != nil {
+			logError("Failed to persist admin state: %v", err)
+		}
+	}
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// setupAdminTest configures an admin token and a temporary state file
+func setupAdminTest(t *testing.T) string {
+	oldConfig := config
+	config = getDefaultConfig()
+	config.Admin.Token = "secret"
+	config.Admin.StateFile = filepath.Join(t.TempDir(), "probe-state.json")
+	t.Cleanup(func() {
+		config = oldConfig
+		adminMutex.Lock()
+		adminState = nil
+		adminMutex.Unlock()
+	})
+
+	adminMutex.Lock()
+	adminState = nil
+	adminMutex.Unlock()
+
+	return config.Admin.StateFile
+}
+
+func TestAdminModeHandlerAuthorization(t *testing.T) {
+	setupAdminTest(t)
+
+	tests := []struct {
+		name       string
+		method     string
+		token      string
+		configured string
+		wantCode   int
+	}{
+		{
+			name:       "valid token",
+			method:     http.MethodPost,
+			token:      "secret",
+			configured: "secret",
+			wantCode:   http.StatusOK,
+		},
+		{
+			name:       "wrong token",
+			method:     http.MethodPost,
+			token:      "guess",
+			configured: "secret",
+			wantCode:   http.StatusUnauthorized,
+		},
+		{
+			name:       "admin disabled",
+			method:     http.MethodPost,
+			token:      "",
+			configured: "",
+			wantCode:   http.StatusForbidden,
+		},
+		{
+			name:       "wrong method",
+			method:     http.MethodGet,
+			token:      "secret",
+			configured: "secret",
+			wantCode:   http.StatusMethodNotAllowed,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config.Admin.Token = tt.configured
+
+			req := httptest.NewRequest(tt.method, "/admin/drain", nil)
+			req.Header.Set("Authorization", "Bearer "+tt.token)
+			recorder := httptest.NewRecorder()
+			adminModeHandler(adminModeDrain)(recorder, req)
+
+			if recorder.Code != tt.wantCode {
+				t.Errorf("status code = %v, want %v", recorder.Code, tt.wantCode)
+			}
+		})
+	}
+}
+
+func TestAdminDrainLifecycle(t *testing.T) {
+	stateFile := setupAdminTest(t)
+
+	cacheMutex.Lock()
+	metricCache = map[string]MetricStatus{
+		"memory": {Current: 10, Max: 90, Status: "OK"},
+	}
+	cacheMutex.Unlock()
+
+	// Enter drain mode
+	body := strings.NewReader(`{"reason": "kernel upgrade", "duration": "1h"}`)
+	req := httptest.NewRequest(http.MethodPost, "/admin/drain", body)
+	req.Header.Set("Authorization", "Bearer secret")
+	recorder := httptest.NewRecorder()
+	adminModeHandler(adminModeDrain)(recorder, req)
+
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("drain status code = %v, want 200: %s", recorder.Code, recorder.Body.String())
+	}
+	if _, err := os.Stat(stateFile); err != nil {
+		t.Errorf("state file not written: %v", err)
+	}
+
+	// Health reports KO with the admin state
+	recorder = httptest.NewRecorder()
+	healthHandler(recorder, httptest.NewRequest(http.MethodGet, "/health", nil))
+
+	var response HealthResponse
+	if err := json.NewDecoder(recorder.Body).Decode(&response); err != nil {
+		t.Fatalf("failed to decode health response: %v", err)
+	}
+	if recorder.Code != http.StatusServiceUnavailable || response.Status != "KO" {
+		t.Errorf("health = %v %v, want 503 KO", recorder.Code, response.Status)
+	}
+	if response.Admin == nil || response.Admin.Mode != adminModeDrain || response.Admin.Reason != "kernel upgrade" {
+		t.Errorf("health admin = %+v, want drain with reason", response.Admin)
+	}
+	if response.Admin != nil && response.Admin.Expires == nil {
+		t.Error("health admin expires not set")
+	}
+
+	// State survives a restart
+	adminMutex.Lock()
+	adminState = nil
+	adminMutex.Unlock()
+	if err := loadAdminState(stateFile); err != nil {
+		t.Fatalf("loadAdminState() returned error: %v", err)
+	}
+	if state := currentAdminState(); state == nil || state.Mode != adminModeDrain {
+		t.Errorf("restored admin state = %+v, want drain", state)
+	}
+
+	// Clear the mode
+	req = httptest.NewRequest(http.MethodPost, "/admin/clear", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	recorder = httptest.NewRecorder()
+	adminClearHandler(recorder, req)
+
+	if recorder.Code != http.StatusNoContent {
+		t.Errorf("clear status code = %v, want 204", recorder.Code)
+	}
+	if state := currentAdminState(); state != nil {
+		t.Errorf("admin state after clear = %+v, want nil", state)
+	}
+	if _, err := os.Stat(stateFile); !os.IsNotExist(err) {
+		t.Errorf("state file still present after clear: %v", err)
+	}
+}
+
+func TestAdminStateExpires(t *testing.T) {
+	setupAdminTest(t)
+
+	expired := time.Now().Add(-time.Minute)
+	if err := setAdminState(&AdminState{Mode: adminModeMaintenance, Since: time.Now().Add(-time.Hour), Expires: &expired}); err != nil {
+		t.Fatalf("setAdminState() returned error: %v", err)
+	}
+
+	if state := currentAdminState(); state != nil {
+		t.Errorf("currentAdminState() = %+v, want nil after expiry", state)
+	}
+}
+
+func TestAdminStateNotPersisted(t *testing.T) {
+	setupAdminTest(t)
+	// The state file sits under a regular file, so it can be neither written nor removed
+	notDir := filepath.Join(t.TempDir(), "not-a-directory")
+	if err := os.WriteFile(notDir, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	config.Admin.StateFile = filepath.Join(notDir, "probe-state.json")
+
+	req := httptest.NewRequest(http.MethodPost, "/admin/drain", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	recorder := httptest.NewRecorder()
+	adminModeHandler(adminModeDrain)(recorder, req)
+
+	if recorder.Code != http.StatusInternalServerError {
+		t.Errorf("drain status code = %v, want 500", recorder.Code)
+	}
+	if state := currentAdminState(); state == nil || state.Mode != adminModeDrain {
+		t.Errorf("admin state = %+v, want drain applied in memory", state)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/admin/clear", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	recorder = httptest.NewRecorder()
+	adminClearHandler(recorder, req)
+
+	if recorder.Code != http.StatusInternalServerError {
+		t.Errorf("clear status code = %v, want 500", recorder.Code)
+	}
+	if state := currentAdminState(); state != nil {
+		t.Errorf("admin state = %+v, want cleared in memory", state)
+	}
+}
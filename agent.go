@@ -0,0 +1,76 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"time"
+)
+
+// serveAgent answers HAProxy agent-check connections on ln.
+// Each connection receives a single status line and is then closed.
+func serveAgent(ln net.Listener) error {
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			return err
+		}
+
+		go handleAgentConn(conn)
+	}
+}
+
+// handleAgentConn writes the current agent response to conn
+func handleAgentConn(conn net.Conn) {
+	defer conn.Close()
+
+	cfg := currentConfig()
+	conn.SetWriteDeadline(time.Now().Add(5 * time.Second))
+	response := agentResponse(snapshotMetrics(), currentAdminState(), cfg.Agent.RampStart, cfg.Agent.MinWeight)
+	if _, err := conn.Write([]byte(response)); err != nil {
+		logDebug(cfg, "Failed to write agent response to %s: %v", conn.RemoteAddr(), err)
+	}
+}
+
+// agentResponse builds the agent-check reply for the given metrics.
+// Admin modes are reported as "drain" or "maint", then the node is reported
+// "down" when any metric is KO, otherwise "up" with a weight derived from
+// how close metrics are to their max.
+func agentResponse(metrics map[string]MetricStatus, admin *AdminState, rampStart, minWeight float64) string {
+	if admin != nil {
+		if admin.Mode == adminModeMaintenance {
+			return "maint\n"
+		}
+		return "drain\n"
+	}
+
+	if overallStatus(metrics) == "KO" {
+		return "down\n"
+	}
+
+	return fmt.Sprintf("up %d%%\n", agentWeight(metrics, rampStart, minWeight))
+}
+
+// agentWeight maps the metric closest to its max to a weight percentage.
+// Below rampStart percent of its max the weight is 100%, then it decreases
+// linearly to minWeight when the metric reaches its max.
+func agentWeight(metrics map[string]MetricStatus, rampStart, minWeight float64) int {
+	// Find the highest usage relative to max, ignoring informational metrics
+	worst := 0.0
+	for _, metric := range metrics {
+		if metric.Max <= 0 {
+			continue
+		}
+		worst = math.Max(worst, metric.Current/metric.Max*100.0)
+	}
+
+	if worst <= rampStart || rampStart >= 100.0 {
+		return 100
+	}
+
+	weight := 100.0 - (worst-rampStart)/(100.0-rampStart)*(100.0-minWeight)
+	weight = math.Max(weight, minWeight)
+
+	// HAProxy treats a weight of 0% as drain
+	return int(math.Max(math.Round(weight), 1))
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"io"
+	"net"
+	"testing"
+)
+
+func TestAgentResponse(t *testing.T) {
+	tests := []struct {
+		name    string
+		metrics map[string]MetricStatus
+		want    string
+	}{
+		{
+			name:    "no metrics",
+			metrics: map[string]MetricStatus{},
+			want:    "up 100%\n",
+		},
+		{
+			name: "below ramp start",
+			metrics: map[string]MetricStatus{
+				"cpu_usage": {Current: 40, Max: 80, Status: "OK"},
+			},
+			want: "up 100%\n",
+		},
+		{
+			name: "halfway through ramp",
+			metrics: map[string]MetricStatus{
+				"cpu_usage": {Current: 60, Max: 80, Status: "OK"},
+				"memory":    {Current: 10, Max: 90, Status: "OK"},
+			},
+			want: "up 55%\n",
+		},
+		{
+			name: "at max",
+			metrics: map[string]MetricStatus{
+				"cpu_usage": {Current: 80, Max: 80, Status: "OK"},
+			},
+			want: "up 10%\n",
+		},
+		{
+			name: "informational metric ignored",
+			metrics: map[string]MetricStatus{
+				"network_lo_bandwidth": {Current: 5000, Max: 0, Status: "OK"},
+			},
+			want: "up 100%\n",
+		},
+		{
+			name: "KO metric",
+			metrics: map[string]MetricStatus{
+				"cpu_usage": {Current: 90, Max: 80, Status: "KO"},
+			},
+			want: "down\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := agentResponse(tt.metrics, nil, 50.0, 10.0)
+			if got != tt.want {
+				t.Errorf("agentResponse() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAgentResponseAdminMode(t *testing.T) {
+	metrics := map[string]MetricStatus{
+		"cpu_usage": {Current: 90, Max: 80, Status: "KO"},
+	}
+
+	if got := agentResponse(metrics, &AdminState{Mode: adminModeDrain}, 50.0, 10.0); got != "drain\n" {
+		t.Errorf("agentResponse() in drain mode = %q, want %q", got, "drain\n")
+	}
+	if got := agentResponse(metrics, &AdminState{Mode: adminModeMaintenance}, 50.0, 10.0); got != "maint\n" {
+		t.Errorf("agentResponse() in maintenance mode = %q, want %q", got, "maint\n")
+	}
+}
+
+func TestServeAgent(t *testing.T) {
+	oldConfig := config
+	config = getDefaultConfig()
+	defer func() { config = oldConfig }()
+
+	cacheMutex.Lock()
+	metricCache = map[string]MetricStatus{
+		"memory": {Current: 10, Max: 90, Status: "OK"},
+	}
+	cacheMutex.Unlock()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("net.Listen() returned error: %v", err)
+	}
+	defer ln.Close()
+	go serveAgent(ln)
+
+	conn, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatalf("net.Dial() returned error: %v", err)
+	}
+	defer conn.Close()
+
+	response, err := io.ReadAll(conn)
+	if err != nil {
+		t.Fatalf("reading agent response returned error: %v", err)
+	}
+	if string(response) != "up 100%\n" {
+		t.Errorf("agent response = %q, want %q", response, "up 100%\n")
+	}
+}
@@ -0,0 +1,330 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// cgroupRoot returns the mount point of the cgroup v2 hierarchy
+func cgroupRoot() string {
+	return sysPath("fs", "cgroup")
+}
+
+// cgroupIOCounters holds io.stat counters summed over all devices
+type cgroupIOCounters struct {
+	readBytes  uint64
+	writeBytes uint64
+	readIOs    uint64
+	writeIOs   uint64
+}
+
+// cgroupSnapshot stores previous cgroup counters for delta calculation
+type cgroupSnapshot struct {
+	dir       string // Cgroup directory the counters were read from
+	cpuStat   map[string]uint64
+	events    map[string]uint64
+	io        cgroupIOCounters
+	timestamp time.Time
+}
+
+var (
+	cgroupCache      *cgroupSnapshot
+	cgroupCacheMutex sync.Mutex
+)
+
+// parseCPUMax parses cpu.max and returns the CPU limit in cores.
+// Format: "$QUOTA $PERIOD" or "max $PERIOD" when unlimited, in which case it returns 0.
+func parseCPUMax(data string) (float64, error) {
+	fields := strings.Fields(data)
+	if len(fields) != 2 {
+		return 0, fmt.Errorf("invalid cpu.max %q", strings.TrimSpace(data))
+	}
+	if fields[0] == "max" {
+		return 0, nil
+	}
+
+	quota, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid cpu.max quota: %w", err)
+	}
+	period, err := strconv.ParseFloat(fields[1], 64)
+	if err != nil || period <= 0 {
+		return 0, fmt.Errorf("invalid cpu.max period %q", fields[1])
+	}
+
+	return quota / period, nil
+}
+
+// parseCgroupLimit parses a single value limit file such as memory.max,
+// returning 0 for "max"
+func parseCgroupLimit(data string) (uint64, error) {
+	value := strings.TrimSpace(data)
+	if value == "max" {
+		return 0, nil
+	}
+	return strconv.ParseUint(value, 10, 64)
+}
+
+// parseCgroupIOStat parses io.stat and sums the counters of every device.
+// Format: 8:0 rbytes=1459200 wbytes=314773504 rios=192 wios=353 dbytes=0 dios=0
+func parseCgroupIOStat(data string) (cgroupIOCounters, error) {
+	var counters cgroupIOCounters
+
+	for _, line := range strings.Split(data, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+
+		for _, field := range fields[1:] {
+			key, value, found := strings.Cut(field, "=")
+			if !found {
+				return cgroupIOCounters{}, fmt.Errorf("%s: invalid field %q", fields[0], field)
+			}
+			parsed, err := strconv.ParseUint(value, 10, 64)
+			if err != nil {
+				return cgroupIOCounters{}, fmt.Errorf("%s: invalid %s: %w", fields[0], key, err)
+			}
+
+			switch key {
+			case "rbytes":
+				counters.readBytes += parsed
+			case "wbytes":
+				counters.writeBytes += parsed
+			case "rios":
+				counters.readIOs += parsed
+			case "wios":
+				counters.writeIOs += parsed
+			}
+		}
+	}
+
+	return counters, nil
+}
+
+// cgroupDir returns the cgroup directory to monitor: the configured path
+// relative to cgroupRoot, or the probe's own cgroup from /proc/self/cgroup
+func cgroupDir(path string) (string, error) {
+	if path != "" {
+		return filepath.Join(cgroupRoot(), path), nil
+	}
+
+	data, err := os.ReadFile(procPath("self", "cgroup"))
+	if err != nil {
+		return "", err
+	}
+
+	// The unified hierarchy entry has the form "0::/path"
+	for _, line := range strings.Split(string(data), "\n") {
+		if own, found := strings.CutPrefix(line, "0::"); found {
+			return filepath.Join(cgroupRoot(), own), nil
+		}
+	}
+
+	return "", fmt.Errorf("no cgroup v2 entry in /proc/self/cgroup")
+}
+
+// readCgroupFile reads a file of the cgroup directory
+func readCgroupFile(dir, name string) (string, error) {
+	data, err := os.ReadFile(filepath.Join(dir, name))
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// cgroupCollector reports CPU, memory and IO usage of a cgroup v2 relative to its limits
+type cgroupCollector struct{}
+
+// Name identifies the collector in logs
+func (c *cgroupCollector) Name() string {
+	return "cgroup"
+}
+
+// Interval is the delay between two cgroup collections
+func (c *cgroupCollector) Interval() time.Duration {
+	return collectorInterval(c.Name(), 2*time.Second)
+}
+
+// Collect returns nothing unless config.Cgroup.Enabled is set. CPU usage is a
+// percentage of cpu.max (or of the online CPUs without a quota) checked against
+// config.Thresholds.MaxCPU, memory a percentage of memory.max (or of MemTotal)
+// checked against MaxMemory, and throttling the percentage of throttled periods
+// checked against MaxCPUThrottled. OOM kills in the cgroup are KO.
+func (c *cgroupCollector) Collect(ctx context.Context) ([]Sample, error) {
+	cfg := currentConfig()
+	if !cfg.Cgroup.Enabled {
+		return nil, nil
+	}
+
+	dir, err := cgroupDir(cfg.Cgroup.Path)
+	if err != nil {
+		return []Sample{cgroupErrorSample(cfg.Cgroup.Path, err)}, err
+	}
+
+	var samples []Sample
+	var errs []error
+	currentTime := time.Now()
+
+	// CPU
+	cpuStatData, err := readCgroupFile(dir, "cpu.stat")
+	if err != nil {
+		return []Sample{cgroupErrorSample(dir, err)}, err
+	}
+	cpuStat, err := parseCounters(cpuStatData)
+	if err != nil {
+		err = fmt.Errorf("cpu.stat: %w", err)
+		return []Sample{cgroupErrorSample(dir, err)}, err
+	}
+
+	cpuLimit := 0.0
+	if data, err := readCgroupFile(dir, "cpu.max"); err == nil {
+		if cpuLimit, err = parseCPUMax(data); err != nil {
+			errs = append(errs, fmt.Errorf("cpu.max: %w", err))
+		}
+	} else if !errors.Is(err, os.ErrNotExist) {
+		// cpu.max is absent from the root cgroup and when the cpu controller is disabled
+		errs = append(errs, fmt.Errorf("cpu.max: %w", err))
+	}
+	cpuCapacity := cpuLimit
+	if cpuCapacity <= 0 {
+		cpuCapacity = float64(getOnlineCPUs())
+	}
+
+	// Memory
+	memoryCurrent, memoryLimit := 0.0, 0.0
+	var events map[string]uint64
+	data, memoryErr := readCgroupFile(dir, "memory.current")
+	if memoryErr == nil {
+		var usage uint64
+		usage, memoryErr = strconv.ParseUint(strings.TrimSpace(data), 10, 64)
+		memoryCurrent = float64(usage)
+
+		if data, err := readCgroupFile(dir, "memory.max"); err == nil {
+			limit, err := parseCgroupLimit(data)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("memory.max: %w", err))
+			}
+			memoryLimit = float64(limit)
+		}
+		if memoryLimit <= 0 {
+			memoryLimit = hostMemoryBytes()
+		}
+
+		if data, err := readCgroupFile(dir, "memory.events"); err == nil {
+			if events, err = parseCounters(data); err != nil {
+				errs = append(errs, fmt.Errorf("memory.events: %w", err))
+			}
+		}
+	}
+	if memoryErr != nil {
+		errs = append(errs, fmt.Errorf("memory.current: %w", memoryErr))
+	}
+
+	// IO, only available when the io controller is enabled for the cgroup
+	var io cgroupIOCounters
+	ioData, ioErr := readCgroupFile(dir, "io.stat")
+	if ioErr == nil {
+		if io, ioErr = parseCgroupIOStat(ioData); ioErr != nil {
+			errs = append(errs, fmt.Errorf("io.stat: %w", ioErr))
+		}
+	}
+
+	current := &cgroupSnapshot{dir: dir, cpuStat: cpuStat, events: events, io: io, timestamp: currentTime}
+	cgroupCacheMutex.Lock()
+	previous := cgroupCache
+	cgroupCache = current
+	cgroupCacheMutex.Unlock()
+
+	// The first reading of a cgroup has no previous counters, and a cgroup
+	// re-created under the same path (e.g. a restarted pod) starts from zero
+	var deltas counterDeltas
+	if previous != nil && previous.dir == dir {
+		deltas.elapsed = currentTime.Sub(previous.timestamp).Seconds()
+	} else {
+		previous = current
+	}
+
+	// usage_usec is in microseconds of CPU time
+	cpuUsage := deltas.rate(previous.cpuStat["usage_usec"], cpuStat["usage_usec"]) / (1e6 * cpuCapacity) * 100.0
+	throttled := 0.0
+	if periods := deltas.delta(previous.cpuStat["nr_periods"], cpuStat["nr_periods"]); periods > 0 {
+		throttled = deltas.delta(previous.cpuStat["nr_throttled"], cpuStat["nr_throttled"]) / periods * 100.0
+	}
+	oomKills := deltas.delta(previous.events["oom_kill"], events["oom_kill"])
+	maxEvents := deltas.delta(previous.events["max"], events["max"])
+	readBytes := deltas.rate(previous.io.readBytes, io.readBytes)
+	writeBytes := deltas.rate(previous.io.writeBytes, io.writeBytes)
+	readIOPS := deltas.rate(previous.io.readIOs, io.readIOs)
+	writeIOPS := deltas.rate(previous.io.writeIOs, io.writeIOs)
+
+	// Values of stale samples are not published
+	stale := deltas.stale()
+
+	memoryPercent := 0.0
+	if memoryLimit > 0 {
+		memoryPercent = memoryCurrent / memoryLimit * 100.0
+	}
+
+	maxThrottled := cfg.Thresholds.MaxCPUThrottled
+	samples = append(samples,
+		Sample{Name: "cgroup_cpu_usage", Value: cpuUsage, Max: cfg.Thresholds.MaxCPU, Stale: stale},
+		Sample{Name: "cgroup_cpu_throttled", Value: throttled, Max: maxThrottled, NoMax: maxThrottled <= 0, Stale: stale},
+		Sample{Name: "cgroup_cpu_limit", Value: cpuLimit, NoMax: true},
+		Sample{Name: "cgroup_memory", Value: memoryPercent, Max: cfg.Thresholds.MaxMemory, Err: memoryErr},
+		Sample{Name: "cgroup_memory_bytes", Value: memoryCurrent, NoMax: true, Err: memoryErr},
+	)
+
+	if events != nil {
+		samples = append(samples,
+			Sample{Name: "cgroup_memory_oom_kills", Value: oomKills, Max: 0, Stale: stale},
+			Sample{Name: "cgroup_memory_max_events", Value: maxEvents, NoMax: true, Stale: stale},
+		)
+	}
+
+	if ioErr == nil {
+		samples = append(samples,
+			Sample{Name: "cgroup_io_read_bytes", Value: readBytes, NoMax: true, Stale: stale},
+			Sample{Name: "cgroup_io_write_bytes", Value: writeBytes, NoMax: true, Stale: stale},
+			Sample{Name: "cgroup_io_read_iops", Value: readIOPS, NoMax: true, Stale: stale},
+			Sample{Name: "cgroup_io_write_iops", Value: writeIOPS, NoMax: true, Stale: stale},
+		)
+	} else {
+		// I/O rates are informational and have no limit
+		ioSample := Sample{Name: "cgroup_io", NoMax: true, Metric: "cgroup_io", Labels: map[string]string{"path": dir}}
+		if errors.Is(ioErr, os.ErrNotExist) {
+			ioSample.Unsupported = true
+		} else {
+			ioSample.Err = ioErr
+		}
+		samples = append(samples, ioSample)
+	}
+
+	return samples, errors.Join(errs...)
+}
+
+// cgroupErrorSample reports a cgroup whose CPU statistics could not be read.
+// It fails the probe under the ko unknown policy, like the CPU and memory usage it replaces.
+func cgroupErrorSample(path string, err error) Sample {
+	return Sample{Name: "cgroup", Err: err, Metric: "cgroup", Labels: map[string]string{"path": path}}
+}
+
+// hostMemoryBytes returns MemTotal from /proc/meminfo in bytes, or 0 when unavailable
+func hostMemoryBytes() float64 {
+	data, err := os.ReadFile(procPath("meminfo"))
+	if err != nil {
+		return 0
+	}
+	meminfo, err := parseMeminfo(string(data))
+	if err != nil {
+		return 0
+	}
+	return float64(meminfo["MemTotal"]) * 1024
+}
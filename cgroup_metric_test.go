@@ -0,0 +1,198 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestParseCPUMax(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		want    float64
+		wantErr bool
+	}{
+		{name: "two cores", data: "200000 100000\n", want: 2},
+		{name: "half core", data: "50000 100000\n", want: 0.5},
+		{name: "unlimited", data: "max 100000\n", want: 0},
+		{name: "invalid quota", data: "abc 100000\n", wantErr: true},
+		{name: "zero period", data: "50000 0\n", wantErr: true},
+		{name: "missing period", data: "50000\n", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseCPUMax(tt.data)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseCPUMax(%q) error = %v, wantErr %v", tt.data, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("parseCPUMax(%q) = %v, want %v", tt.data, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseCgroupIOStat(t *testing.T) {
+	data := `8:0 rbytes=1000 wbytes=2000 rios=10 wios=20 dbytes=0 dios=0
+8:16 rbytes=500 wbytes=500 rios=5 wios=5 dbytes=0 dios=0
+`
+
+	counters, err := parseCgroupIOStat(data)
+	if err != nil {
+		t.Fatalf("parseCgroupIOStat() returned error: %v", err)
+	}
+
+	expected := cgroupIOCounters{readBytes: 1500, writeBytes: 2500, readIOs: 15, writeIOs: 25}
+	if counters != expected {
+		t.Errorf("parseCgroupIOStat() = %+v, want %+v", counters, expected)
+	}
+
+	if _, err := parseCgroupIOStat("8:0 rbytes\n"); err == nil {
+		t.Error("parseCgroupIOStat() expected error for field without value")
+	}
+}
+
+func TestCgroupCollector(t *testing.T) {
+	oldConfig := config
+	config = Config{
+		startTime: time.Now(),
+	}
+	config.Thresholds.MaxCPU = 80
+	config.Thresholds.MaxMemory = 90
+	config.Thresholds.MaxCPUThrottled = 25
+	config.Cgroup.Enabled = true
+	config.Cgroup.Path = "/kubepods/pod1"
+	config.Paths.Sys = t.TempDir()
+	defer func() { config = oldConfig }()
+
+	cgroupCacheMutex.Lock()
+	cgroupCache = nil
+	cgroupCacheMutex.Unlock()
+
+	dir := filepath.Join(cgroupRoot(), "kubepods", "pod1")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	writeFiles := func(files map[string]string) {
+		for name, content := range files {
+			if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	writeFiles(map[string]string{
+		"cpu.stat":       "usage_usec 1000000\nuser_usec 800000\nsystem_usec 200000\nnr_periods 100\nnr_throttled 10\nthrottled_usec 5000\n",
+		"cpu.max":        "200000 100000\n",
+		"memory.current": "536870912\n",
+		"memory.max":     "1073741824\n",
+		"memory.events":  "low 0\nhigh 0\nmax 2\noom 0\noom_kill 0\n",
+	})
+
+	collector := &cgroupCollector{}
+	samples, err := collector.Collect(context.Background())
+	if err != nil {
+		t.Fatalf("cgroupCollector.Collect() returned error: %v", err)
+	}
+
+	values := make(map[string]Sample)
+	for _, sample := range samples {
+		values[sample.Name] = sample
+	}
+
+	if values["cgroup_memory"].Value != 50 || values["cgroup_memory"].Max != 90 {
+		t.Errorf("cgroup_memory = %+v, want 50%% of the cgroup limit with max 90", values["cgroup_memory"])
+	}
+	if values["cgroup_cpu_limit"].Value != 2 {
+		t.Errorf("cgroup_cpu_limit = %v, want 2", values["cgroup_cpu_limit"].Value)
+	}
+	if !values["cgroup_cpu_usage"].Stale || !values["cgroup_cpu_throttled"].Stale || values["cgroup_memory"].Stale {
+		t.Errorf("first collection should only report stale deltas, got %+v and %+v", values["cgroup_cpu_usage"], values["cgroup_cpu_throttled"])
+	}
+	if !values["cgroup_io"].Unsupported {
+		t.Error("cgroup_io should be unsupported without io.stat")
+	}
+
+	// Half of the periods throttled and an OOM kill since the previous reading
+	writeFiles(map[string]string{
+		"cpu.stat":      "usage_usec 1500000\nnr_periods 120\nnr_throttled 20\nthrottled_usec 9000\n",
+		"memory.events": "low 0\nhigh 0\nmax 3\noom 1\noom_kill 1\n",
+	})
+
+	samples, err = collector.Collect(context.Background())
+	if err != nil {
+		t.Fatalf("cgroupCollector.Collect() returned error: %v", err)
+	}
+	for _, sample := range samples {
+		values[sample.Name] = sample
+	}
+
+	if values["cgroup_cpu_throttled"].Value != 50 {
+		t.Errorf("cgroup_cpu_throttled = %v, want 50", values["cgroup_cpu_throttled"].Value)
+	}
+	if values["cgroup_cpu_usage"].Value <= 0 {
+		t.Errorf("cgroup_cpu_usage = %v, want a positive value", values["cgroup_cpu_usage"].Value)
+	}
+	if status := evaluateSample(values["cgroup_memory_oom_kills"], 1).Status; status != "KO" {
+		t.Errorf("cgroup_memory_oom_kills status = %v, want KO", status)
+	}
+	if values["cgroup_memory_max_events"].Value != 1 {
+		t.Errorf("cgroup_memory_max_events = %v, want 1", values["cgroup_memory_max_events"].Value)
+	}
+
+	// The pod restarted and its cgroup was re-created with fresh counters
+	writeFiles(map[string]string{
+		"cpu.stat":      "usage_usec 2000\nnr_periods 2\nnr_throttled 0\nthrottled_usec 0\n",
+		"memory.events": "low 0\nhigh 0\nmax 0\noom 0\noom_kill 0\n",
+	})
+
+	samples, err = collector.Collect(context.Background())
+	if err != nil {
+		t.Fatalf("cgroupCollector.Collect() returned error: %v", err)
+	}
+	for _, sample := range samples {
+		values[sample.Name] = sample
+	}
+
+	for _, name := range []string{"cgroup_cpu_usage", "cgroup_cpu_throttled", "cgroup_memory_oom_kills"} {
+		if status := evaluateSample(values[name], 1).Status; status != "STALE" {
+			t.Errorf("%s status after a counter reset = %v, want STALE", name, status)
+		}
+	}
+}
+
+func TestCgroupCollectorDisabled(t *testing.T) {
+	oldConfig := config
+	config = Config{}
+	defer func() { config = oldConfig }()
+
+	samples, err := (&cgroupCollector{}).Collect(context.Background())
+	if err != nil || len(samples) != 0 {
+		t.Errorf("cgroupCollector.Collect() = %v, %v, want no samples when disabled", samples, err)
+	}
+}
+
+func TestCgroupDirFixture(t *testing.T) {
+	oldConfig := config
+	defer func() { config = oldConfig }()
+
+	useFixture("idle")
+
+	dir, err := cgroupDir("")
+	if err != nil {
+		t.Fatalf("cgroupDir() returned error: %v", err)
+	}
+	if want := filepath.Join("testdata", "idle", "sys", "fs", "cgroup", "system.slice", "probe.service"); dir != want {
+		t.Errorf("cgroupDir() = %v, want %v", dir, want)
+	}
+
+	// The legacy snapshot predates the unified hierarchy
+	useFixture("legacy")
+	if dir, err := cgroupDir(""); err == nil {
+		t.Errorf("cgroupDir() = %v, want an error without /proc/self/cgroup", dir)
+	}
+}
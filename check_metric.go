@@ -0,0 +1,188 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Check types
+const (
+	checkTypeTCP  = "tcp"
+	checkTypeHTTP = "http"
+	checkTypeUnix = "unix"
+)
+
+// Defaults for checks without an interval, timeout or expected status
+const (
+	defaultCheckInterval = 5 * time.Second
+	defaultCheckTimeout  = 2 * time.Second
+	defaultCheckStatus   = http.StatusOK
+)
+
+// maxCheckBody limits how much of an HTTP response is searched for expect_body
+const maxCheckBody = 1 << 20
+
+var (
+	registeredChecks      = make(map[string]bool)
+	registeredChecksMutex sync.Mutex
+)
+
+// checkTimeout returns the timeout of a check, with the default applied
+func checkTimeout(check CheckConfig) time.Duration {
+	if check.Timeout > 0 {
+		return check.Timeout
+	}
+	return defaultCheckTimeout
+}
+
+// runCheck performs a single check and returns its latency
+func runCheck(ctx context.Context, check CheckConfig) (time.Duration, error) {
+	ctx, cancel := context.WithTimeout(ctx, checkTimeout(check))
+	defer cancel()
+
+	start := time.Now()
+	var err error
+	switch check.Type {
+	case checkTypeTCP:
+		err = dialCheck(ctx, "tcp", check.Address)
+	case checkTypeUnix:
+		err = dialCheck(ctx, "unix", check.Address)
+	case checkTypeHTTP:
+		err = httpCheck(ctx, check)
+	default:
+		err = fmt.Errorf("unknown check type %q", check.Type)
+	}
+
+	return time.Since(start), err
+}
+
+// dialCheck opens and closes a connection to address
+func dialCheck(ctx context.Context, network, address string) error {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, network, address)
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
+
+// httpCheck sends a GET request and verifies the status and body of the response.
+// Redirects are not followed so that expect_status applies to the first response.
+func httpCheck(ctx context.Context, check CheckConfig) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, check.Address, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("User-Agent", "probe-lbcdn-go")
+
+	client := &http.Client{
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	expectStatus := check.ExpectStatus
+	if expectStatus == 0 {
+		expectStatus = defaultCheckStatus
+	}
+	if resp.StatusCode != expectStatus {
+		return fmt.Errorf("status %d, expected %d", resp.StatusCode, expectStatus)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxCheckBody))
+	if err != nil {
+		return err
+	}
+	if check.ExpectBody != "" && !strings.Contains(string(body), check.ExpectBody) {
+		return fmt.Errorf("body does not contain %q", check.ExpectBody)
+	}
+
+	return nil
+}
+
+// findCheck returns the configured check with the given name
+func findCheck(cfg Config, name string) (CheckConfig, bool) {
+	for _, check := range cfg.Checks {
+		if check.Name == name {
+			return check, true
+		}
+	}
+	return CheckConfig{}, false
+}
+
+// checkCollector runs one configured check on its own interval.
+// The check is looked up by name on every run so reloaded settings apply.
+type checkCollector struct {
+	name string
+}
+
+// Name identifies the collector in logs
+func (c *checkCollector) Name() string {
+	return "check_" + c.name
+}
+
+// Interval is the delay between two runs of the check
+func (c *checkCollector) Interval() time.Duration {
+	check, exists := findCheck(currentConfig(), c.name)
+	if !exists || check.Interval <= 0 {
+		return defaultCheckInterval
+	}
+	return check.Interval
+}
+
+// Collect runs the check and returns an up sample, KO when the check fails,
+// and a latency sample in milliseconds checked against the timeout.
+// A check removed from the configuration returns no samples.
+func (c *checkCollector) Collect(ctx context.Context) ([]Sample, error) {
+	check, exists := findCheck(currentConfig(), c.name)
+	if !exists {
+		return nil, nil
+	}
+
+	latency, err := runCheck(ctx, check)
+	up := 1.0
+	if err != nil {
+		up = 0
+		err = fmt.Errorf("%s %s: %w", check.Type, check.Address, err)
+	}
+
+	labels := map[string]string{"check": check.Name, "type": check.Type}
+	samples := []Sample{
+		{Name: "check_" + check.Name + "_up", Value: up, NoMax: true, Min: 1, Metric: "check_up", Labels: labels},
+		{
+			Name:   "check_" + check.Name + "_latency",
+			Value:  float64(latency) / float64(time.Millisecond),
+			Max:    float64(checkTimeout(check)) / float64(time.Millisecond),
+			Metric: "check_latency",
+			Labels: labels,
+		},
+	}
+
+	return samples, err
+}
+
+// registerChecks registers a collector for every configured check not registered yet.
+// It is called at startup and after each configuration reload.
+func registerChecks(r *Registry, checks []CheckConfig) {
+	registeredChecksMutex.Lock()
+	defer registeredChecksMutex.Unlock()
+
+	for _, check := range checks {
+		if registeredChecks[check.Name] {
+			continue
+		}
+		registeredChecks[check.Name] = true
+		r.Register(&checkCollector{name: check.Name})
+	}
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRunCheckHTTP(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/health":
+			fmt.Fprint(w, "status: ready")
+		case "/redirect":
+			http.Redirect(w, r, "/health", http.StatusFound)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name    string
+		check   CheckConfig
+		wantErr bool
+	}{
+		{
+			name:  "expected status and body",
+			check: CheckConfig{Type: "http", Address: server.URL + "/health", ExpectBody: "ready"},
+		},
+		{
+			name:    "body mismatch",
+			check:   CheckConfig{Type: "http", Address: server.URL + "/health", ExpectBody: "draining"},
+			wantErr: true,
+		},
+		{
+			name:    "unexpected status",
+			check:   CheckConfig{Type: "http", Address: server.URL + "/missing"},
+			wantErr: true,
+		},
+		{
+			name:  "redirect not followed",
+			check: CheckConfig{Type: "http", Address: server.URL + "/redirect", ExpectStatus: http.StatusFound},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := runCheck(context.Background(), tt.check)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("runCheck() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRunCheckDial(t *testing.T) {
+	tcpListener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	defer tcpListener.Close()
+
+	socket := filepath.Join(t.TempDir(), "app.sock")
+	unixListener, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	defer unixListener.Close()
+
+	// A port that was just released refuses connections
+	closed, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	closedAddress := closed.Addr().String()
+	closed.Close()
+
+	tests := []struct {
+		name    string
+		check   CheckConfig
+		wantErr bool
+	}{
+		{name: "tcp open", check: CheckConfig{Type: "tcp", Address: tcpListener.Addr().String()}},
+		{name: "tcp refused", check: CheckConfig{Type: "tcp", Address: closedAddress}, wantErr: true},
+		{name: "unix socket", check: CheckConfig{Type: "unix", Address: socket}},
+		{name: "missing unix socket", check: CheckConfig{Type: "unix", Address: socket + ".missing"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := runCheck(context.Background(), tt.check)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("runCheck() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCheckCollector(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	oldConfig := config
+	config = Config{
+		startTime: time.Now(),
+	}
+	config.Checks = []CheckConfig{
+		{Name: "web", Type: "http", Address: server.URL, Interval: time.Second, Timeout: 500 * time.Millisecond},
+	}
+	defer func() { config = oldConfig }()
+
+	collector := &checkCollector{name: "web"}
+	if collector.Interval() != time.Second {
+		t.Errorf("Interval() = %v, want 1s", collector.Interval())
+	}
+
+	samples, err := collector.Collect(context.Background())
+	if err == nil {
+		t.Error("Collect() expected error for a 503 response")
+	}
+	if len(samples) != 2 {
+		t.Fatalf("Collect() returned %d samples, want 2", len(samples))
+	}
+
+	up := evaluateSample(samples[0], 1.0)
+	if samples[0].Name != "check_web_up" || up.Status != "KO" {
+		t.Errorf("%s status = %v, want KO", samples[0].Name, up.Status)
+	}
+	if samples[1].Name != "check_web_latency" || samples[1].Max != 500 {
+		t.Errorf("%s max = %v, want the 500ms timeout", samples[1].Name, samples[1].Max)
+	}
+
+	// A removed check stops reporting
+	config.Checks = nil
+	if samples, err := collector.Collect(context.Background()); samples != nil || err != nil {
+		t.Errorf("Collect() after removal = %v, %v, want no samples", samples, err)
+	}
+	if collector.Interval() != defaultCheckInterval {
+		t.Errorf("Interval() after removal = %v, want default", collector.Interval())
+	}
+}
+
+func TestRegisterChecks(t *testing.T) {
+	registeredChecksMutex.Lock()
+	registeredChecks = make(map[string]bool)
+	registeredChecksMutex.Unlock()
+
+	registry := newRegistry()
+	checks := []CheckConfig{{Name: "a"}, {Name: "b"}}
+	registerChecks(registry, checks)
+	registerChecks(registry, append(checks, CheckConfig{Name: "c"}))
+
+	if len(registry.collectors) != 3 {
+		t.Errorf("registry has %d collectors, want 3", len(registry.collectors))
+	}
+}
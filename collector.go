@@ -0,0 +1,399 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// Sample is a single metric reading produced by a Collector
+type Sample struct {
+	Name  string  // Key under which the metric is stored in metricCache
+	Value float64 // Current reading
+	Max   float64 // Configured threshold, scaled by the warmup factor when published
+	NoMax bool    // Informational sample that is never compared against Max
+	Min   float64 // Minimum value, e.g. a process count; 0 for no minimum
+
+	// Unsupported marks a metric the host cannot provide, e.g. a missing /proc file
+	Unsupported bool
+
+	// Stale marks a rate with no valid value yet: the first reading of a
+	// counter, or a reading discarded after a counter reset
+	Stale bool
+
+	// Err marks a metric that could not be read, reported as UNKNOWN
+	// with the error message instead of a misleading zero value
+	Err error
+
+	// Metric is the family name used for exposition, defaulting to Name.
+	// Labels distinguish samples of the same family, e.g. disk paths.
+	Metric string
+	Labels map[string]string
+}
+
+// Collector gathers a family of metrics on its own schedule.
+// Collectors only read values; thresholds, warmup and caching are
+// handled centrally by the Registry.
+type Collector interface {
+	// Name identifies the collector in logs
+	Name() string
+	// Interval is the delay between two collections
+	Interval() time.Duration
+	// Collect returns the current samples. Samples returned together
+	// with an error are still published. ctx ends after the collection timeout.
+	Collect(ctx context.Context) ([]Sample, error)
+}
+
+// collectorNames lists the built-in collectors, whose schedule can be set under collectors
+var collectorNames = []string{"cpu", "memory", "disk", "diskio", "network", "psi", "load", "cgroup", "fd", "process"}
+
+// isCollectorName reports whether name is a built-in collector
+func isCollectorName(name string) bool {
+	for _, collector := range collectorNames {
+		if collector == name {
+			return true
+		}
+	}
+	return false
+}
+
+// collectorInterval returns the configured interval of a collector, or def when unset
+func collectorInterval(name string, def time.Duration) time.Duration {
+	if interval := currentConfig().Collectors[name].Interval; interval > 0 {
+		return interval
+	}
+	return def
+}
+
+// collectionTimeout returns the maximum duration of a single collection of c:
+// its configured timeout, or its interval when unset
+func collectionTimeout(c Collector) time.Duration {
+	if timeout := currentConfig().Collectors[c.Name()].Timeout; timeout > 0 {
+		return timeout
+	}
+	return c.Interval()
+}
+
+var (
+	blockedCalls      = make(map[string]bool) // Keys of calls still running after their context ended
+	blockedCallsMutex sync.Mutex
+)
+
+// callWithContext runs a call that may block in the kernel, such as statfs on
+// a hung NFS mount, and gives up when ctx is done. The blocked call keeps
+// running in the background and later calls with the same key fail at once
+// until it returns, so a hung mount holds a single goroutine.
+func callWithContext(ctx context.Context, key string, call func() error) error {
+	blockedCallsMutex.Lock()
+	if blockedCalls[key] {
+		blockedCallsMutex.Unlock()
+		return fmt.Errorf("%s: previous call still blocked", key)
+	}
+	blockedCalls[key] = true
+	blockedCallsMutex.Unlock()
+
+	done := make(chan error, 1)
+	go func() {
+		err := call()
+		blockedCallsMutex.Lock()
+		delete(blockedCalls, key)
+		blockedCallsMutex.Unlock()
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return fmt.Errorf("%s: %w", key, ctx.Err())
+	}
+}
+
+// Registry schedules collectors and publishes their samples to metricCache
+type Registry struct {
+	mu         sync.Mutex
+	collectors []Collector
+	ctx        context.Context
+	published  map[Collector]map[string]bool // Cache keys written by each collector
+	updated    map[Collector]time.Time       // Time of the registration or last collection of each collector
+	running    sync.WaitGroup                // Goroutines started by Start and Register
+}
+
+// newRegistry returns an empty collector registry
+func newRegistry() *Registry {
+	return &Registry{
+		published: make(map[Collector]map[string]bool),
+		updated:   make(map[Collector]time.Time),
+	}
+}
+
+// Register adds a collector to the registry.
+// Collectors registered after Start are started immediately.
+func (r *Registry) Register(c Collector) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.collectors = append(r.collectors, c)
+	r.updated[c] = time.Now()
+	if r.ctx != nil {
+		r.goRun(r.ctx, c)
+	}
+}
+
+// Start launches one goroutine per registered collector.
+// Collectors stop when ctx is cancelled.
+func (r *Registry) Start(ctx context.Context) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.ctx = ctx
+	for _, c := range r.collectors {
+		r.goRun(ctx, c)
+	}
+
+	r.running.Add(1)
+	go func() {
+		defer r.running.Done()
+		r.watchStale(ctx)
+	}()
+}
+
+// Wait blocks until every collector has stopped after the Start context is cancelled
+func (r *Registry) Wait() {
+	r.running.Wait()
+}
+
+// goRun starts a goroutine collecting from c, tracked by Wait
+func (r *Registry) goRun(ctx context.Context, c Collector) {
+	r.running.Add(1)
+	go func() {
+		defer r.running.Done()
+		r.run(ctx, c)
+	}()
+}
+
+// run collects from c every interval until ctx is cancelled
+func (r *Registry) run(ctx context.Context, c Collector) {
+	for {
+		r.collectOnce(ctx, c)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(c.Interval()):
+		}
+	}
+}
+
+// collectOnce runs a single collection, bounded by the collector timeout, and publishes its samples
+func (r *Registry) collectOnce(ctx context.Context, c Collector) {
+	ctx, cancel := context.WithTimeout(ctx, collectionTimeout(c))
+	defer cancel()
+
+	samples, err := c.Collect(ctx)
+	if err != nil {
+		log.Printf("Error collecting %s metrics: %v", c.Name(), err)
+		// A collection that failed without any sample leaves the metrics to go stale
+		if len(samples) == 0 {
+			return
+		}
+	}
+	publishSamples(samples)
+	r.forgetMissing(c, samples)
+}
+
+// watchStale checks every second for collectors that stopped updating their metrics
+func (r *Registry) watchStale(ctx context.Context) {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			r.markStale(now)
+		}
+	}
+}
+
+// markStale reports the metrics of every collector that has not completed a
+// collection within config.Unknown.StaleAfter intervals as UNKNOWN, e.g. a
+// collector blocked on a hung NFS mount. A collector that never completed one
+// is reported as a single UNKNOWN metric named after it.
+func (r *Registry) markStale(now time.Time) {
+	staleAfter := currentConfig().Unknown.StaleAfter
+
+	r.mu.Lock()
+	for c, registered := range r.updated {
+		if _, collected := r.published[c]; !collected && markUncollected(c.Name(), registered, c.Interval(), staleAfter, now) {
+			// The next collection replaces the placeholder
+			r.published[c] = map[string]bool{c.Name(): true}
+		}
+	}
+	published := make(map[Collector]map[string]bool, len(r.published))
+	for c, names := range r.published {
+		published[c] = names
+	}
+	updated := make(map[Collector]time.Time, len(r.updated))
+	for c, at := range r.updated {
+		updated[c] = at
+	}
+	r.mu.Unlock()
+
+	for c, names := range published {
+		markStaleMetrics(names, updated[c], c.Interval(), staleAfter, now)
+	}
+}
+
+// forgetMissing removes cache entries that c published previously but not in samples,
+// e.g. after a disk path or interface is removed from the configuration
+func (r *Registry) forgetMissing(c Collector, samples []Sample) {
+	current := make(map[string]bool, len(samples))
+	for _, sample := range samples {
+		current[sample.Name] = true
+	}
+
+	r.mu.Lock()
+	previous := r.published[c]
+	r.published[c] = current
+	r.updated[c] = time.Now()
+	r.mu.Unlock()
+
+	cacheMutex.Lock()
+	defer cacheMutex.Unlock()
+
+	for name := range previous {
+		if !current[name] {
+			delete(metricCache, name)
+		}
+	}
+}
+
+// thresholdSample builds a sample from an optional threshold; a zero max means no limit
+func thresholdSample(name string, value, max float64) Sample {
+	return Sample{
+		Name:  name,
+		Value: value,
+		Max:   max,
+		NoMax: max <= 0,
+	}
+}
+
+// failSamples marks samples whose values could not be read with err
+func failSamples(samples []Sample, err error) {
+	for i := range samples {
+		samples[i].Err = err
+	}
+}
+
+// publishSamples applies warmup and thresholds to samples and stores them in metricCache
+func publishSamples(samples []Sample) {
+	warmupFactor := currentWarmupFactor()
+
+	cacheMutex.Lock()
+	defer cacheMutex.Unlock()
+
+	for _, sample := range samples {
+		metric := evaluateSample(sample, warmupFactor)
+		previous, exists := metricCache[sample.Name]
+		if sample.Err != nil {
+			metric = applyUnknownPolicy(metric, previous, exists, unknownPolicy(sample.Name, metric.metric))
+		} else if (!sample.NoMax || sample.Min > 0) && !sample.Unsupported && !sample.Stale {
+			metric = debounceStatus(metric, previous, exists, hysteresisRule(sample.Name, metric.metric))
+		}
+		if metric.Status == "UNKNOWN" || metric.Status == "STALE" {
+			metric.lastKnown = lastKnownStatus(previous)
+		}
+		metricCache[sample.Name] = metric
+	}
+}
+
+// evaluateSample compares a sample against its warmup-adjusted maximum and its minimum
+func evaluateSample(sample Sample, warmupFactor float64) MetricStatus {
+	metric := sample.Metric
+	if metric == "" {
+		metric = sample.Name
+	}
+
+	if sample.Unsupported {
+		return MetricStatus{
+			Current: 0,
+			Max:     0,
+			Status:  "UNSUPPORTED",
+			metric:  metric,
+			labels:  sample.Labels,
+		}
+	}
+
+	// Neither unknown nor stale metrics have a current value
+	if sample.Err != nil || sample.Stale {
+		unset := MetricStatus{
+			Min:    sample.Min,
+			Status: "STALE",
+			metric: metric,
+			labels: sample.Labels,
+			noMax:  sample.NoMax,
+		}
+		if sample.Err != nil {
+			unset.Status = "UNKNOWN"
+			unset.Error = sample.Err.Error()
+		}
+		if !sample.NoMax {
+			unset.Max = sample.Max * warmupFactor
+		}
+		return unset
+	}
+
+	// The minimum is not relaxed by warmup
+	status := "OK"
+	if sample.Value < sample.Min {
+		status = "KO"
+	}
+
+	if sample.NoMax {
+		return MetricStatus{
+			Current: sample.Value,
+			Max:     0,
+			Min:     sample.Min,
+			Status:  status,
+			metric:  metric,
+			labels:  sample.Labels,
+			noMax:   true,
+		}
+	}
+
+	effectiveMax := sample.Max * warmupFactor
+	if sample.Value > effectiveMax {
+		status = "KO"
+	}
+
+	return MetricStatus{
+		Current: sample.Value,
+		Max:     effectiveMax,
+		Min:     sample.Min,
+		Status:  status,
+		metric:  metric,
+		labels:  sample.Labels,
+	}
+}
+
+// currentWarmupFactor returns the factor applied to thresholds, 1.0 when warmup is disabled
+func currentWarmupFactor() float64 {
+	if currentConfig().Warmup.Enabled {
+		return getWarmupFactor()
+	}
+	return 1.0
+}
+
+// getWarmupFactor returns a factor between 0.0 and 1.0 based on elapsed time
+func getWarmupFactor() float64 {
+	cfg := currentConfig()
+	elapsed := time.Since(cfg.startTime)
+	if elapsed >= cfg.Warmup.Duration {
+		return 1.0
+	}
+	return elapsed.Seconds() / cfg.Warmup.Duration.Seconds()
+}
@@ -0,0 +1,342 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+	"time"
+)
+
+// fakeCollector returns a fixed set of samples
+type fakeCollector struct {
+	samples []Sample
+	err     error
+}
+
+func (c *fakeCollector) Name() string {
+	return "fake"
+}
+
+func (c *fakeCollector) Interval() time.Duration {
+	return 10 * time.Millisecond
+}
+
+func (c *fakeCollector) Collect(ctx context.Context) ([]Sample, error) {
+	return c.samples, c.err
+}
+
+func TestEvaluateSample(t *testing.T) {
+	tests := []struct {
+		name         string
+		sample       Sample
+		warmupFactor float64
+		wantMax      float64
+		wantStatus   string
+	}{
+		{
+			name:         "within limits",
+			sample:       Sample{Name: "test", Value: 50.0, Max: 80.0},
+			warmupFactor: 1.0,
+			wantMax:      80.0,
+			wantStatus:   "OK",
+		},
+		{
+			name:         "at limit",
+			sample:       Sample{Name: "test", Value: 80.0, Max: 80.0},
+			warmupFactor: 1.0,
+			wantMax:      80.0,
+			wantStatus:   "OK",
+		},
+		{
+			name:         "exceeds limit",
+			sample:       Sample{Name: "test", Value: 85.0, Max: 80.0},
+			warmupFactor: 1.0,
+			wantMax:      80.0,
+			wantStatus:   "KO",
+		},
+		{
+			name:         "exceeds warmup limit",
+			sample:       Sample{Name: "test", Value: 50.0, Max: 80.0},
+			warmupFactor: 0.5,
+			wantMax:      40.0,
+			wantStatus:   "KO",
+		},
+		{
+			name:         "unsupported sample",
+			sample:       Sample{Name: "test", Value: 5.0, Max: 1.0, Unsupported: true},
+			warmupFactor: 1.0,
+			wantMax:      0,
+			wantStatus:   "UNSUPPORTED",
+		},
+		{
+			name:         "stale rate keeps its warmup limit",
+			sample:       Sample{Name: "test", Max: 80.0, Stale: true},
+			warmupFactor: 0.5,
+			wantMax:      40.0,
+			wantStatus:   "STALE",
+		},
+		{
+			name:         "unreadable sample is unknown",
+			sample:       Sample{Name: "test", Max: 80.0, Err: os.ErrNotExist},
+			warmupFactor: 1.0,
+			wantMax:      80.0,
+			wantStatus:   "UNKNOWN",
+		},
+		{
+			name:         "below minimum",
+			sample:       Sample{Name: "test", Value: 0, NoMax: true, Min: 1},
+			warmupFactor: 0.5,
+			wantMax:      0,
+			wantStatus:   "KO",
+		},
+		{
+			name:         "at minimum",
+			sample:       Sample{Name: "test", Value: 2, Max: 10, Min: 2},
+			warmupFactor: 1.0,
+			wantMax:      10,
+			wantStatus:   "OK",
+		},
+		{
+			name:         "informational sample",
+			sample:       Sample{Name: "test", Value: 5000.0, NoMax: true},
+			warmupFactor: 1.0,
+			wantMax:      0,
+			wantStatus:   "OK",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := evaluateSample(tt.sample, tt.warmupFactor)
+			if got.Current != tt.sample.Value && !tt.sample.Unsupported {
+				t.Errorf("current = %v, want %v", got.Current, tt.sample.Value)
+			}
+			if got.Max != tt.wantMax {
+				t.Errorf("max = %v, want %v", got.Max, tt.wantMax)
+			}
+			if got.Status != tt.wantStatus {
+				t.Errorf("status = %v, want %v", got.Status, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestRegistryPublishesSamples(t *testing.T) {
+	// Setup test config
+	oldConfig := config
+	config = Config{
+		startTime: time.Now(),
+	}
+	config.Warmup.Enabled = false
+	defer func() { config = oldConfig }()
+
+	// Clear cache
+	cacheMutex.Lock()
+	metricCache = make(map[string]MetricStatus)
+	cacheMutex.Unlock()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	registry := newRegistry()
+	registry.Register(&fakeCollector{samples: []Sample{
+		{Name: "fake_ok", Value: 1.0, Max: 2.0},
+	}})
+	registry.Start(ctx)
+
+	// Collectors registered after Start must run too
+	registry.Register(&fakeCollector{samples: []Sample{
+		{Name: "fake_ko", Value: 3.0, Max: 2.0},
+	}})
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		cacheMutex.RLock()
+		okMetric, okExists := metricCache["fake_ok"]
+		koMetric, koExists := metricCache["fake_ko"]
+		cacheMutex.RUnlock()
+
+		if okExists && koExists {
+			if okMetric.Status != "OK" {
+				t.Errorf("fake_ok status = %v, want OK", okMetric.Status)
+			}
+			if koMetric.Status != "KO" {
+				t.Errorf("fake_ko status = %v, want KO", koMetric.Status)
+			}
+			return
+		}
+
+		if time.Now().After(deadline) {
+			t.Fatal("Registry did not publish samples in time")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestGetWarmupFactor(t *testing.T) {
+	oldConfig := config
+	defer func() { config = oldConfig }()
+
+	config = Config{
+		startTime: time.Now().Add(-30 * time.Second),
+	}
+	config.Warmup.Duration = 60 * time.Second
+
+	factor := getWarmupFactor()
+	if factor < 0.49 || factor > 0.51 {
+		t.Errorf("getWarmupFactor() = %v, want about 0.5", factor)
+	}
+
+	config.startTime = time.Now().Add(-2 * time.Minute)
+	if factor := getWarmupFactor(); factor != 1.0 {
+		t.Errorf("getWarmupFactor() after warmup = %v, want 1.0", factor)
+	}
+}
+
+func TestRegistryForgetsMissingSamples(t *testing.T) {
+	oldConfig := config
+	config = Config{
+		startTime: time.Now(),
+	}
+	defer func() { config = oldConfig }()
+
+	cacheMutex.Lock()
+	metricCache = make(map[string]MetricStatus)
+	cacheMutex.Unlock()
+
+	registry := newRegistry()
+	collector := &fakeCollector{samples: []Sample{
+		{Name: "disk_var", Value: 1.0, Max: 2.0},
+		{Name: "disk_tmp", Value: 1.0, Max: 2.0},
+	}}
+	registry.collectOnce(context.Background(), collector)
+
+	// Simulate a path removed from the configuration
+	collector.samples = collector.samples[:1]
+	registry.collectOnce(context.Background(), collector)
+
+	cacheMutex.RLock()
+	_, varExists := metricCache["disk_var"]
+	_, tmpExists := metricCache["disk_tmp"]
+	cacheMutex.RUnlock()
+
+	if !varExists {
+		t.Error("disk_var metric missing from cache")
+	}
+	if tmpExists {
+		t.Error("disk_tmp metric still in cache after removal")
+	}
+}
+
+// blockingCollector blocks until its context is done
+type blockingCollector struct{}
+
+func (c *blockingCollector) Name() string {
+	return "disk"
+}
+
+func (c *blockingCollector) Interval() time.Duration {
+	return time.Hour
+}
+
+func (c *blockingCollector) Collect(ctx context.Context) ([]Sample, error) {
+	<-ctx.Done()
+	return []Sample{{Name: "blocked", Max: 1, Err: ctx.Err()}}, ctx.Err()
+}
+
+func TestRegistryCollectionTimeout(t *testing.T) {
+	oldConfig := config
+	config = Config{
+		startTime: time.Now(),
+	}
+	config.Collectors = map[string]CollectorConfig{"disk": {Timeout: 20 * time.Millisecond}}
+	defer func() { config = oldConfig }()
+
+	cacheMutex.Lock()
+	metricCache = make(map[string]MetricStatus)
+	cacheMutex.Unlock()
+
+	start := time.Now()
+	newRegistry().collectOnce(context.Background(), &blockingCollector{})
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("collectOnce() took %v, want the 20ms collector timeout", elapsed)
+	}
+
+	if metric := snapshotMetrics()["blocked"]; metric.Status != "UNKNOWN" {
+		t.Errorf("blocked status = %v, want UNKNOWN", metric.Status)
+	}
+}
+
+func TestCollectorIntervalAndTimeout(t *testing.T) {
+	oldConfig := config
+	defer func() { config = oldConfig }()
+
+	config = Config{}
+	config.Collectors = map[string]CollectorConfig{
+		"cpu":  {Interval: 500 * time.Millisecond},
+		"disk": {Interval: 30 * time.Second, Timeout: 10 * time.Second},
+	}
+
+	tests := []struct {
+		collector    Collector
+		wantInterval time.Duration
+		wantTimeout  time.Duration
+	}{
+		{collector: &cpuCollector{}, wantInterval: 500 * time.Millisecond, wantTimeout: 500 * time.Millisecond},
+		{collector: &diskCollector{}, wantInterval: 30 * time.Second, wantTimeout: 10 * time.Second},
+		{collector: &memoryCollector{}, wantInterval: 2 * time.Second, wantTimeout: 2 * time.Second},
+		{collector: &fdCollector{}, wantInterval: 5 * time.Second, wantTimeout: 5 * time.Second},
+	}
+
+	for _, tt := range tests {
+		if interval := tt.collector.Interval(); interval != tt.wantInterval {
+			t.Errorf("%s interval = %v, want %v", tt.collector.Name(), interval, tt.wantInterval)
+		}
+		if timeout := collectionTimeout(tt.collector); timeout != tt.wantTimeout {
+			t.Errorf("%s timeout = %v, want %v", tt.collector.Name(), timeout, tt.wantTimeout)
+		}
+	}
+}
+
+func TestCallWithContext(t *testing.T) {
+	release := make(chan struct{})
+	blocked := func() error {
+		<-release
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := callWithContext(ctx, "statfs /mnt/nfs", blocked); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("callWithContext() on a blocked call = %v, want deadline exceeded", err)
+	}
+
+	// The hung call is not started again while it is still blocked
+	started := false
+	err := callWithContext(context.Background(), "statfs /mnt/nfs", func() error {
+		started = true
+		return nil
+	})
+	if err == nil || started {
+		t.Fatalf("callWithContext() while blocked = %v (started %v), want an immediate error", err, started)
+	}
+
+	// Once the blocked call returns, the key is usable again
+	close(release)
+	deadline := time.Now().Add(time.Second)
+	for {
+		err := callWithContext(context.Background(), "statfs /mnt/nfs", func() error { return nil })
+		if err == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("callWithContext() after release = %v, want nil", err)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	if err := callWithContext(context.Background(), "statfs /", func() error { return os.ErrNotExist }); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("callWithContext() = %v, want the call error", err)
+	}
+}
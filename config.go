@@ -3,8 +3,12 @@ package main
 import (
 	"flag"
 	"fmt"
+	"net"
+	"net/url"
 	"os"
 	"path/filepath"
+	"regexp"
+	"strconv"
 	"time"
 
 	"gopkg.in/yaml.v3"
@@ -16,6 +20,18 @@ type Config struct {
 		Port string `yaml:"port"`
 	} `yaml:"server"`
 
+	Admin struct {
+		Token     string `yaml:"token"`
+		StateFile string `yaml:"state_file"`
+	} `yaml:"admin"`
+
+	Agent struct {
+		Enabled   bool    `yaml:"enabled"`
+		Port      string  `yaml:"port"`
+		RampStart float64 `yaml:"ramp_start"` // Percent of a max above which the weight starts to decrease
+		MinWeight float64 `yaml:"min_weight"` // Weight reported when a metric reaches its max
+	} `yaml:"agent"`
+
 	Warmup struct {
 		Enabled  bool          `yaml:"enabled"`
 		Duration time.Duration `yaml:"duration"`
@@ -26,16 +42,90 @@ type Config struct {
 		MaxIOWait      float64 `yaml:"max_iowait"`
 		MaxIRQ         float64 `yaml:"max_irq"`
 		MaxSoftIRQ     float64 `yaml:"max_softirq"`
-		MaxMemory      float64 `yaml:"max_memory"`
-		MaxDisk        float64 `yaml:"max_disk"`
+		MaxSteal       float64 `yaml:"max_steal"`        // 0 to disable
+		MaxCoreUsage   float64 `yaml:"max_core_usage"`   // Busiest single core, 0 to disable
+		MaxCoreSoftIRQ float64 `yaml:"max_core_softirq"` // Highest single core softirq, 0 to disable
+
+		// Percentage of cgroup CPU periods throttled by cpu.max, 0 to disable
+		MaxCPUThrottled float64 `yaml:"max_cpu_throttled"`
+
+		MaxMemory float64 `yaml:"max_memory"`
+		MaxSwap   float64 `yaml:"max_swap"` // Used swap percentage, 0 to disable
+
+		MaxDisk   float64 `yaml:"max_disk"`
+		MaxInodes float64 `yaml:"max_inodes"` // Used inode percentage, 0 to disable
+
+		// Block device busy percentage and average request latency in milliseconds, 0 to disable
+		MaxDiskUtil  float64 `yaml:"max_disk_util"`
+		MaxDiskAwait float64 `yaml:"max_disk_await"`
+
 		MaxConnections float64 `yaml:"max_connections"`
+
+		// Maximum rx or tx rate as a percentage of the link speed, 0 to disable
+		MaxLinkUtilization float64 `yaml:"max_link_utilization"`
+
+		// Maximum socket count per TCP state, e.g. close_wait or syn_recv
+		MaxTCPStates map[string]float64 `yaml:"max_tcp_states"`
+
+		// Pressure stall avg10 percentages, 0 to disable
+		MaxPSICPUSome    float64 `yaml:"max_psi_cpu_some"`
+		MaxPSICPUFull    float64 `yaml:"max_psi_cpu_full"`
+		MaxPSIMemorySome float64 `yaml:"max_psi_memory_some"`
+		MaxPSIMemoryFull float64 `yaml:"max_psi_memory_full"`
+		MaxPSIIOSome     float64 `yaml:"max_psi_io_some"`
+		MaxPSIIOFull     float64 `yaml:"max_psi_io_full"`
+
+		// Load average and runnable tasks, 0 to disable.
+		// With load_per_core, values are multiplied by the online CPU count.
+		MaxLoad1        float64 `yaml:"max_load1"`
+		MaxLoad5        float64 `yaml:"max_load5"`
+		MaxLoad15       float64 `yaml:"max_load15"`
+		MaxProcsRunning float64 `yaml:"max_procs_running"`
+		LoadPerCore     bool    `yaml:"load_per_core"`
+
+		// File descriptor and process table usage percentages, 0 to disable
+		MaxFDUsage        float64 `yaml:"max_fd_usage"`         // Open files versus fs.file-max
+		MaxPIDUsage       float64 `yaml:"max_pid_usage"`        // Tasks versus kernel.pid_max
+		MaxThreadUsage    float64 `yaml:"max_thread_usage"`     // Tasks versus kernel.threads-max
+		MaxProcessFDUsage float64 `yaml:"max_process_fd_usage"` // Open files of a monitored process versus its RLIMIT_NOFILE
 	} `yaml:"thresholds"`
 
+	Hysteresis struct {
+		HysteresisRule `yaml:",inline"`
+		Metrics        map[string]HysteresisRule `yaml:"metrics,omitempty"`
+	} `yaml:"hysteresis"`
+
+	// Unknown controls metrics that could not be read or are no longer updated
+	Unknown struct {
+		Policy     string            `yaml:"policy"`            // ok, ko or ignore
+		StaleAfter int               `yaml:"stale_after"`       // Missed collection intervals before metrics are UNKNOWN, 0 to disable
+		Metrics    map[string]string `yaml:"metrics,omitempty"` // Policy per metric family or name
+	} `yaml:"unknown"`
+
+	// Cgroup mode reports resource usage of a cgroup v2 against its own limits
+	Cgroup struct {
+		Enabled bool   `yaml:"enabled"`
+		Path    string `yaml:"path"` // Relative to /sys/fs/cgroup, empty for the probe's own cgroup
+	} `yaml:"cgroup"`
+
+	// Roots of procfs and sysfs, e.g. a host's /proc bind-mounted into a container
+	Paths struct {
+		Proc string `yaml:"proc"`
+		Sys  string `yaml:"sys"`
+	} `yaml:"paths"`
+
 	Monitoring struct {
-		DiskPaths         []string `yaml:"disk_paths"`
-		NetworkInterfaces []string `yaml:"network_interfaces"`
+		DiskPaths         []DiskPath         `yaml:"disk_paths"`
+		NetworkInterfaces []NetworkInterface `yaml:"network_interfaces"`
+		Ports             []PortMonitor      `yaml:"ports"`
+		Processes         []ProcessMonitor   `yaml:"processes"`
 	} `yaml:"monitoring"`
 
+	Checks []CheckConfig `yaml:"checks"`
+
+	// Interval and timeout of each built-in collector by name, e.g. cpu or disk
+	Collectors map[string]CollectorConfig `yaml:"collectors"`
+
 	Logging struct {
 		File  string `yaml:"file"`
 		Debug bool   `yaml:"debug"`
@@ -46,16 +136,207 @@ type Config struct {
 		Interval time.Duration `yaml:"interval"`
 	} `yaml:"display"`
 
+	Reload struct {
+		Watch    bool          `yaml:"watch"`
+		Interval time.Duration `yaml:"interval"`
+	} `yaml:"reload"`
+
+	// Shutdown on SIGTERM or SIGINT: KO is reported for the drain period
+	// before the server stops, then in-flight requests get up to the timeout
+	Shutdown struct {
+		DrainPeriod time.Duration `yaml:"drain_period"`
+		Timeout     time.Duration `yaml:"timeout"`
+	} `yaml:"shutdown"`
+
 	// Runtime fields (not in YAML)
 	startTime time.Time `yaml:"-"`
 }
 
+// HysteresisRule controls how many consecutive samples are needed to change a metric status.
+// Zero values fall back to the global hysteresis settings.
+type HysteresisRule struct {
+	KOAfter  int     `yaml:"ko_after,omitempty"` // Consecutive samples above max to go KO
+	OKAfter  int     `yaml:"ok_after,omitempty"` // Consecutive samples at or below recovery to return OK
+	Recovery float64 `yaml:"recovery,omitempty"` // Percentage of max a sample must not exceed to count toward recovery
+}
+
+// DiskPath is a monitored filesystem path with optional threshold overrides.
+// It can be written in YAML as a plain path string. An override set to 0
+// disables the limit for the path.
+type DiskPath struct {
+	Path      string   `yaml:"path"`
+	MaxDisk   *float64 `yaml:"max_disk,omitempty"`   // Overrides thresholds.max_disk
+	MaxInodes *float64 `yaml:"max_inodes,omitempty"` // Overrides thresholds.max_inodes
+
+	ExpectReadOnly bool `yaml:"expect_readonly,omitempty"` // Read-only by design, e.g. squashfs
+}
+
+// NetworkInterface is a monitored network interface with optional limits.
+// It can be written in YAML as a plain interface name.
+type NetworkInterface struct {
+	Name         string  `yaml:"name"`
+	MaxRxBytes   float64 `yaml:"max_rx_bytes,omitempty"`   // Maximum received bytes/sec
+	MaxTxBytes   float64 `yaml:"max_tx_bytes,omitempty"`   // Maximum transmitted bytes/sec
+	MaxRxPackets float64 `yaml:"max_rx_packets,omitempty"` // Maximum received packets/sec
+	MaxTxPackets float64 `yaml:"max_tx_packets,omitempty"` // Maximum transmitted packets/sec
+	LinkSpeed    float64 `yaml:"link_speed,omitempty"`     // Expected link speed in Mbit/s
+}
+
+// UnmarshalYAML accepts either a plain path or a structured entry
+func (d *DiskPath) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		*d = DiskPath{}
+		return node.Decode(&d.Path)
+	}
+
+	type plain DiskPath
+	return node.Decode((*plain)(d))
+}
+
+// MarshalYAML writes entries without overrides as a plain path
+func (d DiskPath) MarshalYAML() (interface{}, error) {
+	if d.MaxDisk == nil && d.MaxInodes == nil && !d.ExpectReadOnly {
+		return d.Path, nil
+	}
+
+	type plain DiskPath
+	return plain(d), nil
+}
+
+// String returns the monitored path
+func (d DiskPath) String() string {
+	return d.Path
+}
+
+// UnmarshalYAML accepts either a plain interface name or a structured entry
+func (n *NetworkInterface) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		*n = NetworkInterface{}
+		return node.Decode(&n.Name)
+	}
+
+	type plain NetworkInterface
+	return node.Decode((*plain)(n))
+}
+
+// MarshalYAML writes entries without limits as a plain interface name
+func (n NetworkInterface) MarshalYAML() (interface{}, error) {
+	if n == (NetworkInterface{Name: n.Name}) {
+		return n.Name, nil
+	}
+
+	type plain NetworkInterface
+	return plain(n), nil
+}
+
+// String returns the interface name
+func (n NetworkInterface) String() string {
+	return n.Name
+}
+
+// PortMonitor is a monitored local TCP port with optional limits.
+// It can be written in YAML as a plain port number.
+type PortMonitor struct {
+	Port           uint16  `yaml:"port"`
+	MaxConnections float64 `yaml:"max_connections,omitempty"`  // Maximum established connections
+	MaxSynRecv     float64 `yaml:"max_syn_recv,omitempty"`     // Maximum half-open connections
+	MaxAcceptQueue float64 `yaml:"max_accept_queue,omitempty"` // Maximum accept queue depth
+}
+
+// UnmarshalYAML accepts either a plain port number or a structured entry
+func (p *PortMonitor) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		*p = PortMonitor{}
+		return node.Decode(&p.Port)
+	}
+
+	type plain PortMonitor
+	return node.Decode((*plain)(p))
+}
+
+// MarshalYAML writes entries without limits as a plain port number
+func (p PortMonitor) MarshalYAML() (interface{}, error) {
+	if p == (PortMonitor{Port: p.Port}) {
+		return p.Port, nil
+	}
+
+	type plain PortMonitor
+	return plain(p), nil
+}
+
+// String returns the port number
+func (p PortMonitor) String() string {
+	return strconv.Itoa(int(p.Port))
+}
+
+// ProcessMonitor is a monitored process with optional limits.
+// It can be written in YAML as a plain process name.
+type ProcessMonitor struct {
+	Name       string  `yaml:"name"`                   // Metric name, and process name in /proc/<pid>/comm without cmdline or pidfile
+	Cmdline    string  `yaml:"cmdline,omitempty"`      // Regular expression matched against the command line
+	PIDFile    string  `yaml:"pidfile,omitempty"`      // File holding the PID of a single process
+	MinCount   int     `yaml:"min_count,omitempty"`    // Minimum running instances, 1 when unset
+	MaxRSS     float64 `yaml:"max_rss,omitempty"`      // Maximum total resident memory in MB
+	MaxCPU     float64 `yaml:"max_cpu,omitempty"`      // Maximum total CPU percentage, 100 per core
+	MaxFDUsage float64 `yaml:"max_fd_usage,omitempty"` // Overrides thresholds.max_process_fd_usage
+}
+
+// UnmarshalYAML accepts either a plain process name or a structured entry
+func (p *ProcessMonitor) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		*p = ProcessMonitor{}
+		return node.Decode(&p.Name)
+	}
+
+	type plain ProcessMonitor
+	return node.Decode((*plain)(p))
+}
+
+// MarshalYAML writes entries without limits as a plain process name
+func (p ProcessMonitor) MarshalYAML() (interface{}, error) {
+	if p == (ProcessMonitor{Name: p.Name}) {
+		return p.Name, nil
+	}
+
+	type plain ProcessMonitor
+	return plain(p), nil
+}
+
+// String returns the process name
+func (p ProcessMonitor) String() string {
+	return p.Name
+}
+
+// CheckConfig is an active check of a local dependency
+type CheckConfig struct {
+	Name         string        `yaml:"name"`
+	Type         string        `yaml:"type"`                    // tcp, http or unix
+	Address      string        `yaml:"address"`                 // host:port, URL or socket path
+	Interval     time.Duration `yaml:"interval,omitempty"`      // 5s when unset
+	Timeout      time.Duration `yaml:"timeout,omitempty"`       // 2s when unset
+	ExpectStatus int           `yaml:"expect_status,omitempty"` // HTTP status, 200 when unset
+	ExpectBody   string        `yaml:"expect_body,omitempty"`   // Substring required in the HTTP body
+}
+
+// String returns the check name, type and address
+func (c CheckConfig) String() string {
+	return fmt.Sprintf("%s (%s %s)", c.Name, c.Type, c.Address)
+}
+
+// CollectorConfig overrides the schedule of a collector
+type CollectorConfig struct {
+	Interval time.Duration `yaml:"interval,omitempty"` // Delay between two collections, the collector default when unset
+	Timeout  time.Duration `yaml:"timeout,omitempty"`  // Maximum duration of a collection, the interval when unset
+}
+
 // CommandLineFlags holds parsed command line arguments
 type CommandLineFlags struct {
 	ConfigFile     string
 	GenerateConfig bool
 	Debug          bool
 	Display        bool
+	ProcRoot       string
+	SysRoot        string
 	Help           bool
 }
 
@@ -67,6 +348,12 @@ func getDefaultConfig() Config {
 	}
 	exeDir := filepath.Dir(exePath)
 	defaultLogFile := filepath.Join(exeDir, "probe.log")
+	// The state directory provided by systemd (StateDirectory=) is writable
+	stateDir := exeDir
+	if dir := os.Getenv("STATE_DIRECTORY"); dir != "" {
+		stateDir = dir
+	}
+	defaultStateFile := filepath.Join(stateDir, "probe-state.json")
 
 	config := Config{
 		startTime: time.Now(),
@@ -74,6 +361,14 @@ func getDefaultConfig() Config {
 
 	config.Server.Port = ":8080"
 
+	config.Admin.Token = ""
+	config.Admin.StateFile = defaultStateFile
+
+	config.Agent.Enabled = false
+	config.Agent.Port = ":8081"
+	config.Agent.RampStart = 50.0
+	config.Agent.MinWeight = 10.0
+
 	config.Warmup.Enabled = true
 	config.Warmup.Duration = 60 * time.Second
 
@@ -81,12 +376,56 @@ func getDefaultConfig() Config {
 	config.Thresholds.MaxIOWait = 20.0
 	config.Thresholds.MaxIRQ = 5.0
 	config.Thresholds.MaxSoftIRQ = 10.0
+	config.Thresholds.MaxSteal = 0
+	config.Thresholds.MaxCoreUsage = 0
+	config.Thresholds.MaxCoreSoftIRQ = 0
+	config.Thresholds.MaxCPUThrottled = 0
 	config.Thresholds.MaxMemory = 90.0
+	config.Thresholds.MaxSwap = 0
 	config.Thresholds.MaxDisk = 95.0
+	config.Thresholds.MaxInodes = 95.0
+	config.Thresholds.MaxDiskUtil = 0
+	config.Thresholds.MaxDiskAwait = 0
 	config.Thresholds.MaxConnections = 1000.0
-
-	config.Monitoring.DiskPaths = []string{"/", "/var", "/tmp"}
-	config.Monitoring.NetworkInterfaces = []string{"eth0", "lo"}
+	config.Thresholds.MaxLinkUtilization = 0
+	config.Thresholds.MaxTCPStates = map[string]float64{}
+	config.Thresholds.MaxPSICPUSome = 0
+	config.Thresholds.MaxPSICPUFull = 0
+	config.Thresholds.MaxPSIMemorySome = 0
+	config.Thresholds.MaxPSIMemoryFull = 0
+	config.Thresholds.MaxPSIIOSome = 0
+	config.Thresholds.MaxPSIIOFull = 0
+	config.Thresholds.MaxLoad1 = 0
+	config.Thresholds.MaxLoad5 = 0
+	config.Thresholds.MaxLoad15 = 0
+	config.Thresholds.MaxProcsRunning = 0
+	config.Thresholds.LoadPerCore = true
+	config.Thresholds.MaxFDUsage = 0
+	config.Thresholds.MaxPIDUsage = 0
+	config.Thresholds.MaxThreadUsage = 0
+	config.Thresholds.MaxProcessFDUsage = 0
+
+	config.Hysteresis.KOAfter = 1
+	config.Hysteresis.OKAfter = 1
+	config.Hysteresis.Recovery = 100.0
+
+	config.Unknown.Policy = unknownPolicyKO
+	config.Unknown.StaleAfter = 3
+
+	config.Cgroup.Enabled = false
+	config.Cgroup.Path = ""
+
+	config.Paths.Proc = defaultProcRoot
+	config.Paths.Sys = defaultSysRoot
+
+	config.Monitoring.DiskPaths = []DiskPath{{Path: "/"}, {Path: "/var"}, {Path: "/tmp"}}
+	config.Monitoring.NetworkInterfaces = []NetworkInterface{{Name: "eth0"}, {Name: "lo"}}
+	config.Monitoring.Ports = []PortMonitor{}
+	config.Monitoring.Processes = []ProcessMonitor{}
+
+	config.Checks = []CheckConfig{}
+
+	config.Collectors = map[string]CollectorConfig{}
 
 	config.Logging.File = defaultLogFile
 	config.Logging.Debug = false
@@ -94,6 +433,12 @@ func getDefaultConfig() Config {
 	config.Display.Enabled = false
 	config.Display.Interval = 3 * time.Second
 
+	config.Reload.Watch = false
+	config.Reload.Interval = 5 * time.Second
+
+	config.Shutdown.DrainPeriod = 10 * time.Second
+	config.Shutdown.Timeout = 10 * time.Second
+
 	return config
 }
 
@@ -107,6 +452,8 @@ func parseCommandLineFlags() CommandLineFlags {
 	flag.BoolVar(&flags.Debug, "debug", false, "Enable debug logging")
 	flag.BoolVar(&flags.Debug, "d", false, "Enable debug logging (short)")
 	flag.BoolVar(&flags.Display, "display", false, "Enable terminal metrics display")
+	flag.StringVar(&flags.ProcRoot, "proc-root", "", "Override the procfs root (paths.proc)")
+	flag.StringVar(&flags.SysRoot, "sys-root", "", "Override the sysfs root (paths.sys)")
 	flag.BoolVar(&flags.Help, "help", false, "Show help")
 	flag.BoolVar(&flags.Help, "h", false, "Show help (short)")
 
@@ -125,6 +472,7 @@ func showHelp() {
 	fmt.Printf("  %s --generate-config          # Generate default config file\n", os.Args[0])
 	fmt.Printf("  %s --config myconfig.yaml     # Use custom config file\n", os.Args[0])
 	fmt.Printf("  %s --debug --display          # Run with debug logs and terminal display\n", os.Args[0])
+	fmt.Printf("  %s --proc-root /host/proc     # Monitor a host's /proc from a container\n", os.Args[0])
 }
 
 // generateConfigFile creates a default configuration file
@@ -160,10 +508,6 @@ func loadConfig(flags CommandLineFlags) (Config, error) {
 		if err != nil {
 			return config, fmt.Errorf("failed to parse config file: %w", err)
 		}
-
-		fmt.Printf("Loaded configuration from: %s\n", flags.ConfigFile)
-	} else {
-		fmt.Printf("Config file not found, using defaults: %s\n", flags.ConfigFile)
 	}
 
 	// Apply command line overrides
@@ -173,6 +517,272 @@ func loadConfig(flags CommandLineFlags) (Config, error) {
 	if flags.Display {
 		config.Display.Enabled = true
 	}
+	if flags.ProcRoot != "" {
+		config.Paths.Proc = flags.ProcRoot
+	}
+	if flags.SysRoot != "" {
+		config.Paths.Sys = flags.SysRoot
+	}
+
+	if err := validateConfig(config); err != nil {
+		return config, fmt.Errorf("invalid configuration: %w", err)
+	}
 
 	return config, nil
 }
+
+// validateConfig checks that configuration values are usable
+func validateConfig(config Config) error {
+	if config.Server.Port == "" {
+		return fmt.Errorf("server.port must not be empty")
+	}
+
+	if config.Admin.StateFile != "" {
+		if err := checkStateDir(config.Admin.StateFile); err != nil {
+			return fmt.Errorf("admin.state_file %s: %w", config.Admin.StateFile, err)
+		}
+	}
+
+	if config.Warmup.Enabled && config.Warmup.Duration <= 0 {
+		return fmt.Errorf("warmup.duration must be positive when warmup is enabled")
+	}
+
+	thresholds := map[string]float64{
+		"max_cpu":           config.Thresholds.MaxCPU,
+		"max_iowait":        config.Thresholds.MaxIOWait,
+		"max_irq":           config.Thresholds.MaxIRQ,
+		"max_softirq":       config.Thresholds.MaxSoftIRQ,
+		"max_steal":         config.Thresholds.MaxSteal,
+		"max_core_usage":    config.Thresholds.MaxCoreUsage,
+		"max_core_softirq":  config.Thresholds.MaxCoreSoftIRQ,
+		"max_cpu_throttled": config.Thresholds.MaxCPUThrottled,
+		"max_memory":        config.Thresholds.MaxMemory,
+		"max_swap":          config.Thresholds.MaxSwap,
+		"max_disk":          config.Thresholds.MaxDisk,
+		"max_inodes":        config.Thresholds.MaxInodes,
+		"max_disk_await":    config.Thresholds.MaxDiskAwait,
+		"max_connections":   config.Thresholds.MaxConnections,
+		"max_load1":         config.Thresholds.MaxLoad1,
+		"max_load5":         config.Thresholds.MaxLoad5,
+		"max_load15":        config.Thresholds.MaxLoad15,
+		"max_procs_running": config.Thresholds.MaxProcsRunning,
+	}
+	for name, value := range thresholds {
+		if value < 0 {
+			return fmt.Errorf("thresholds.%s must not be negative", name)
+		}
+	}
+
+	psiThresholds := map[string]float64{
+		"max_psi_cpu_some":    config.Thresholds.MaxPSICPUSome,
+		"max_psi_cpu_full":    config.Thresholds.MaxPSICPUFull,
+		"max_psi_memory_some": config.Thresholds.MaxPSIMemorySome,
+		"max_psi_memory_full": config.Thresholds.MaxPSIMemoryFull,
+		"max_psi_io_some":     config.Thresholds.MaxPSIIOSome,
+		"max_psi_io_full":     config.Thresholds.MaxPSIIOFull,
+	}
+	for name, value := range psiThresholds {
+		if value < 0 || value > 100 {
+			return fmt.Errorf("thresholds.%s must be between 0 and 100", name)
+		}
+	}
+
+	percentages := map[string]float64{
+		"max_disk_util":        config.Thresholds.MaxDiskUtil,
+		"max_fd_usage":         config.Thresholds.MaxFDUsage,
+		"max_pid_usage":        config.Thresholds.MaxPIDUsage,
+		"max_thread_usage":     config.Thresholds.MaxThreadUsage,
+		"max_process_fd_usage": config.Thresholds.MaxProcessFDUsage,
+	}
+	for name, value := range percentages {
+		if value < 0 || value > 100 {
+			return fmt.Errorf("thresholds.%s must be between 0 and 100", name)
+		}
+	}
+
+	if config.Thresholds.MaxLinkUtilization < 0 || config.Thresholds.MaxLinkUtilization > 100 {
+		return fmt.Errorf("thresholds.max_link_utilization must be between 0 and 100")
+	}
+	for state, value := range config.Thresholds.MaxTCPStates {
+		if !isTCPStateName(state) {
+			return fmt.Errorf("thresholds.max_tcp_states: unknown TCP state %q", state)
+		}
+		if value < 0 {
+			return fmt.Errorf("thresholds.max_tcp_states.%s must not be negative", state)
+		}
+	}
+
+	rules := map[string]HysteresisRule{"hysteresis": config.Hysteresis.HysteresisRule}
+	for name, rule := range config.Hysteresis.Metrics {
+		rules["hysteresis.metrics."+name] = rule
+	}
+	for name, rule := range rules {
+		if rule.KOAfter < 0 || rule.OKAfter < 0 {
+			return fmt.Errorf("%s sample counts must not be negative", name)
+		}
+		if rule.Recovery < 0 || rule.Recovery > 100 {
+			return fmt.Errorf("%s.recovery must be between 0 and 100", name)
+		}
+	}
+
+	policies := map[string]string{"unknown.policy": config.Unknown.Policy}
+	for name, policy := range config.Unknown.Metrics {
+		policies["unknown.metrics."+name] = policy
+	}
+	for name, policy := range policies {
+		if !isUnknownPolicy(policy) {
+			return fmt.Errorf("%s must be ok, ko or ignore", name)
+		}
+	}
+	if config.Unknown.StaleAfter < 0 {
+		return fmt.Errorf("unknown.stale_after must not be negative")
+	}
+
+	for _, disk := range config.Monitoring.DiskPaths {
+		if !filepath.IsAbs(disk.Path) {
+			return fmt.Errorf("monitoring.disk_paths entry %q must be an absolute path", disk.Path)
+		}
+		if disk.MaxDisk != nil && (*disk.MaxDisk < 0 || *disk.MaxDisk > 100) {
+			return fmt.Errorf("monitoring.disk_paths entry %q: max_disk must be between 0 and 100", disk.Path)
+		}
+		if disk.MaxInodes != nil && (*disk.MaxInodes < 0 || *disk.MaxInodes > 100) {
+			return fmt.Errorf("monitoring.disk_paths entry %q: max_inodes must be between 0 and 100", disk.Path)
+		}
+	}
+
+	for _, iface := range config.Monitoring.NetworkInterfaces {
+		if iface.Name == "" {
+			return fmt.Errorf("monitoring.network_interfaces entries must have a name")
+		}
+		if iface.MaxRxBytes < 0 || iface.MaxTxBytes < 0 || iface.MaxRxPackets < 0 || iface.MaxTxPackets < 0 || iface.LinkSpeed < 0 {
+			return fmt.Errorf("monitoring.network_interfaces entry %q: limits must not be negative", iface.Name)
+		}
+	}
+
+	ports := make(map[uint16]bool)
+	for _, port := range config.Monitoring.Ports {
+		if port.Port == 0 {
+			return fmt.Errorf("monitoring.ports entries must have a non-zero port")
+		}
+		if ports[port.Port] {
+			return fmt.Errorf("monitoring.ports entry %d is listed twice", port.Port)
+		}
+		ports[port.Port] = true
+		if port.MaxConnections < 0 || port.MaxSynRecv < 0 || port.MaxAcceptQueue < 0 {
+			return fmt.Errorf("monitoring.ports entry %d: limits must not be negative", port.Port)
+		}
+	}
+
+	processes := make(map[string]bool)
+	for _, process := range config.Monitoring.Processes {
+		if process.Name == "" {
+			return fmt.Errorf("monitoring.processes entries must have a name")
+		}
+		if processes[process.Name] {
+			return fmt.Errorf("monitoring.processes entry %q is listed twice", process.Name)
+		}
+		processes[process.Name] = true
+		if process.MaxFDUsage < 0 || process.MaxFDUsage > 100 {
+			return fmt.Errorf("monitoring.processes entry %q: max_fd_usage must be between 0 and 100", process.Name)
+		}
+		if process.MinCount < 0 || process.MaxRSS < 0 || process.MaxCPU < 0 {
+			return fmt.Errorf("monitoring.processes entry %q: limits must not be negative", process.Name)
+		}
+		if process.PIDFile != "" && process.Cmdline != "" {
+			return fmt.Errorf("monitoring.processes entry %q: pidfile and cmdline are mutually exclusive", process.Name)
+		}
+		if process.PIDFile != "" && !filepath.IsAbs(process.PIDFile) {
+			return fmt.Errorf("monitoring.processes entry %q: pidfile must be an absolute path", process.Name)
+		}
+		if _, err := regexp.Compile(process.Cmdline); err != nil {
+			return fmt.Errorf("monitoring.processes entry %q: invalid cmdline: %w", process.Name, err)
+		}
+	}
+
+	checks := make(map[string]bool)
+	for _, check := range config.Checks {
+		if check.Name == "" {
+			return fmt.Errorf("checks entries must have a name")
+		}
+		if checks[check.Name] {
+			return fmt.Errorf("checks entry %q is listed twice", check.Name)
+		}
+		checks[check.Name] = true
+		if check.Address == "" {
+			return fmt.Errorf("checks entry %q must have an address", check.Name)
+		}
+		if check.Interval < 0 || check.Timeout < 0 {
+			return fmt.Errorf("checks entry %q: interval and timeout must not be negative", check.Name)
+		}
+		switch check.Type {
+		case checkTypeTCP:
+			if _, _, err := net.SplitHostPort(check.Address); err != nil {
+				return fmt.Errorf("checks entry %q: %w", check.Name, err)
+			}
+		case checkTypeHTTP:
+			target, err := url.Parse(check.Address)
+			if err != nil || (target.Scheme != "http" && target.Scheme != "https") || target.Host == "" {
+				return fmt.Errorf("checks entry %q: address must be an http or https URL", check.Name)
+			}
+		case checkTypeUnix:
+			if !filepath.IsAbs(check.Address) {
+				return fmt.Errorf("checks entry %q: address must be an absolute socket path", check.Name)
+			}
+		default:
+			return fmt.Errorf("checks entry %q: type must be tcp, http or unix", check.Name)
+		}
+		if check.Type != checkTypeHTTP && (check.ExpectStatus != 0 || check.ExpectBody != "") {
+			return fmt.Errorf("checks entry %q: expect_status and expect_body only apply to http checks", check.Name)
+		}
+	}
+
+	for name, collector := range config.Collectors {
+		if !isCollectorName(name) {
+			return fmt.Errorf("collectors: unknown collector %q", name)
+		}
+		if collector.Interval < 0 || collector.Timeout < 0 {
+			return fmt.Errorf("collectors.%s: interval and timeout must not be negative", name)
+		}
+	}
+
+	if config.Agent.Enabled && config.Agent.Port == "" {
+		return fmt.Errorf("agent.port must not be empty when the agent is enabled")
+	}
+	if config.Agent.RampStart < 0 || config.Agent.RampStart > 100 {
+		return fmt.Errorf("agent.ramp_start must be between 0 and 100")
+	}
+	if config.Agent.MinWeight < 0 || config.Agent.MinWeight > 100 {
+		return fmt.Errorf("agent.min_weight must be between 0 and 100")
+	}
+
+	if config.Display.Enabled && config.Display.Interval <= 0 {
+		return fmt.Errorf("display.interval must be positive when display is enabled")
+	}
+
+	if config.Reload.Watch && config.Reload.Interval <= 0 {
+		return fmt.Errorf("reload.interval must be positive when watch is enabled")
+	}
+
+	if config.Shutdown.DrainPeriod < 0 {
+		return fmt.Errorf("shutdown.drain_period must not be negative")
+	}
+	if config.Shutdown.Timeout <= 0 {
+		return fmt.Errorf("shutdown.timeout must be positive")
+	}
+
+	return nil
+}
+
+// currentConfig returns the active configuration
+func currentConfig() Config {
+	configMutex.RLock()
+	defer configMutex.RUnlock()
+	return config
+}
+
+// setConfig replaces the active configuration
+func setConfig(newConfig Config) {
+	configMutex.Lock()
+	defer configMutex.Unlock()
+	config = newConfig
+}
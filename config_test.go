@@ -0,0 +1,254 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+func float64Ptr(v float64) *float64 {
+	return &v
+}
+
+func TestValidateConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		modify  func(c *Config)
+		wantErr bool
+	}{
+		{
+			name:    "defaults",
+			modify:  func(c *Config) {},
+			wantErr: false,
+		},
+		{
+			name:    "negative threshold",
+			modify:  func(c *Config) { c.Thresholds.MaxMemory = -5 },
+			wantErr: true,
+		},
+		{
+			name:    "disk util above 100",
+			modify:  func(c *Config) { c.Thresholds.MaxDiskUtil = 150 },
+			wantErr: true,
+		},
+		{
+			name:    "invalid unknown policy",
+			modify:  func(c *Config) { c.Unknown.Metrics = map[string]string{"disk": "warn"} },
+			wantErr: true,
+		},
+		{
+			name:    "disk path override above 100",
+			modify:  func(c *Config) { c.Monitoring.DiskPaths = []DiskPath{{Path: "/", MaxDisk: float64Ptr(150)}} },
+			wantErr: true,
+		},
+		{
+			name:    "disk path override disabling the limit",
+			modify:  func(c *Config) { c.Monitoring.DiskPaths = []DiskPath{{Path: "/", MaxDisk: float64Ptr(0)}} },
+			wantErr: false,
+		},
+		{
+			name:    "state file in a missing directory",
+			modify:  func(c *Config) { c.Admin.StateFile = "/nonexistent/probe-state.json" },
+			wantErr: true,
+		},
+		{
+			name:    "state file disabled",
+			modify:  func(c *Config) { c.Admin.StateFile = "" },
+			wantErr: false,
+		},
+		{
+			name:    "negative stale_after",
+			modify:  func(c *Config) { c.Unknown.StaleAfter = -1 },
+			wantErr: true,
+		},
+		{
+			name:    "unknown collector",
+			modify:  func(c *Config) { c.Collectors = map[string]CollectorConfig{"gpu": {Interval: time.Second}} },
+			wantErr: true,
+		},
+		{
+			name:    "negative collector timeout",
+			modify:  func(c *Config) { c.Collectors = map[string]CollectorConfig{"disk": {Timeout: -time.Second}} },
+			wantErr: true,
+		},
+		{
+			name:    "collector interval",
+			modify:  func(c *Config) { c.Collectors = map[string]CollectorConfig{"disk": {Interval: 30 * time.Second}} },
+			wantErr: false,
+		},
+		{
+			name:    "zero shutdown timeout",
+			modify:  func(c *Config) { c.Shutdown.Timeout = 0 },
+			wantErr: true,
+		},
+		{
+			name:    "duplicate process",
+			modify:  func(c *Config) { c.Monitoring.Processes = []ProcessMonitor{{Name: "nginx"}, {Name: "nginx"}} },
+			wantErr: true,
+		},
+		{
+			name: "http check without URL scheme",
+			modify: func(c *Config) {
+				c.Checks = []CheckConfig{{Name: "web", Type: "http", Address: "localhost:80"}}
+			},
+			wantErr: true,
+		},
+		{
+			name: "tcp check with expected body",
+			modify: func(c *Config) {
+				c.Checks = []CheckConfig{{Name: "web", Type: "tcp", Address: "127.0.0.1:80", ExpectBody: "ok"}}
+			},
+			wantErr: true,
+		},
+		{
+			name: "valid checks",
+			modify: func(c *Config) {
+				c.Checks = []CheckConfig{
+					{Name: "web", Type: "http", Address: "http://127.0.0.1/health", ExpectStatus: 204},
+					{Name: "cache", Type: "tcp", Address: "127.0.0.1:6081"},
+					{Name: "php", Type: "unix", Address: "/run/php/php-fpm.sock"},
+				}
+			},
+			wantErr: false,
+		},
+		{
+			name:    "relative disk path",
+			modify:  func(c *Config) { c.Monitoring.DiskPaths = []DiskPath{{Path: "var"}} },
+			wantErr: true,
+		},
+		{
+			name:    "empty port",
+			modify:  func(c *Config) { c.Server.Port = "" },
+			wantErr: true,
+		},
+		{
+			name:    "duplicate port",
+			modify:  func(c *Config) { c.Monitoring.Ports = []PortMonitor{{Port: 80}, {Port: 80}} },
+			wantErr: true,
+		},
+		{
+			name: "watch without interval",
+			modify: func(c *Config) {
+				c.Reload.Watch = true
+				c.Reload.Interval = 0
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := getDefaultConfig()
+			tt.modify(&cfg)
+			err := validateConfig(cfg)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("validateConfig() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestMonitoringEntriesYAML(t *testing.T) {
+	input := `
+disk_paths:
+    - /
+    - path: /var/cache
+      max_disk: 85
+      max_inodes: 90
+    - path: /var/spool/cache
+      max_disk: 0
+    - path: /usr
+      expect_readonly: true
+network_interfaces:
+    - lo
+    - name: eth0
+      max_rx_bytes: 1000000
+      link_speed: 10000
+ports:
+    - 80
+    - port: 443
+      max_syn_recv: 100
+processes:
+    - nginx
+    - name: varnishd
+      max_fd_usage: 80
+`
+	var monitoring struct {
+		DiskPaths         []DiskPath         `yaml:"disk_paths"`
+		NetworkInterfaces []NetworkInterface `yaml:"network_interfaces"`
+		Ports             []PortMonitor      `yaml:"ports"`
+		Processes         []ProcessMonitor   `yaml:"processes"`
+	}
+	if err := yaml.Unmarshal([]byte(input), &monitoring); err != nil {
+		t.Fatalf("yaml.Unmarshal() returned error: %v", err)
+	}
+
+	wantDisks := []DiskPath{
+		{Path: "/"},
+		{Path: "/var/cache", MaxDisk: float64Ptr(85), MaxInodes: float64Ptr(90)},
+		{Path: "/var/spool/cache", MaxDisk: float64Ptr(0)}, // An explicit 0 disables the limit
+		{Path: "/usr", ExpectReadOnly: true},
+	}
+	if len(monitoring.DiskPaths) != len(wantDisks) {
+		t.Fatalf("DiskPaths = %+v, want %+v", monitoring.DiskPaths, wantDisks)
+	}
+	for i, want := range wantDisks {
+		if !reflect.DeepEqual(monitoring.DiskPaths[i], want) {
+			t.Errorf("DiskPaths[%d] = %+v, want %+v", i, monitoring.DiskPaths[i], want)
+		}
+	}
+
+	wantIfaces := []NetworkInterface{
+		{Name: "lo"},
+		{Name: "eth0", MaxRxBytes: 1000000, LinkSpeed: 10000},
+	}
+	if len(monitoring.NetworkInterfaces) != len(wantIfaces) {
+		t.Fatalf("NetworkInterfaces = %+v, want %+v", monitoring.NetworkInterfaces, wantIfaces)
+	}
+	for i, want := range wantIfaces {
+		if monitoring.NetworkInterfaces[i] != want {
+			t.Errorf("NetworkInterfaces[%d] = %+v, want %+v", i, monitoring.NetworkInterfaces[i], want)
+		}
+	}
+
+	wantPorts := []PortMonitor{
+		{Port: 80},
+		{Port: 443, MaxSynRecv: 100},
+	}
+	if len(monitoring.Ports) != len(wantPorts) {
+		t.Fatalf("Ports = %+v, want %+v", monitoring.Ports, wantPorts)
+	}
+	for i, want := range wantPorts {
+		if monitoring.Ports[i] != want {
+			t.Errorf("Ports[%d] = %+v, want %+v", i, monitoring.Ports[i], want)
+		}
+	}
+
+	wantProcesses := []ProcessMonitor{
+		{Name: "nginx"},
+		{Name: "varnishd", MaxFDUsage: 80},
+	}
+	if len(monitoring.Processes) != len(wantProcesses) {
+		t.Fatalf("Processes = %+v, want %+v", monitoring.Processes, wantProcesses)
+	}
+	for i, want := range wantProcesses {
+		if monitoring.Processes[i] != want {
+			t.Errorf("Processes[%d] = %+v, want %+v", i, monitoring.Processes[i], want)
+		}
+	}
+
+	// Entries without overrides are written back as plain strings
+	output, err := yaml.Marshal(&monitoring)
+	if err != nil {
+		t.Fatalf("yaml.Marshal() returned error: %v", err)
+	}
+	if !strings.Contains(string(output), "- /\n") || !strings.Contains(string(output), "- lo\n") {
+		t.Errorf("yaml.Marshal() did not write plain entries:\n%s", output)
+	}
+	if !strings.Contains(string(output), "max_disk: 85") {
+		t.Errorf("yaml.Marshal() lost structured entry:\n%s", output)
+	}
+}
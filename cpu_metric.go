@@ -1,9 +1,13 @@
 package main
 
 import (
+	"context"
 	"fmt"
-	"log"
+	"math"
 	"os"
+	"sort"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 )
@@ -14,158 +18,235 @@ type cpuMetrics struct {
 	IOWait  float64 // IO wait percentage
 	IRQ     float64 // Hardware interrupt percentage
 	SoftIRQ float64 // Software interrupt percentage
+	Steal   float64 // Time stolen by the hypervisor
+	Guest   float64 // Time spent running guests (guest + guest_nice), included in Usage
+	Busy    float64 // Time not idle, waiting or stolen (usage + irq + softirq)
+	Stale   bool    // No valid percentages: first reading or counters reset
 }
 
 // cpuSnapshot stores previous CPU readings for delta calculation
 type cpuSnapshot struct {
-	user    uint64
-	nice    uint64
-	system  uint64
-	idle    uint64
-	iowait  uint64
-	irq     uint64
-	softirq uint64
-	steal   uint64
+	user      uint64
+	nice      uint64
+	system    uint64
+	idle      uint64
+	iowait    uint64
+	irq       uint64
+	softirq   uint64
+	steal     uint64
+	guest     uint64
+	guestNice uint64
 }
 
 var (
-	cpuCache       cpuSnapshot
-	cpuCacheMutex  sync.Mutex
-	cpuInitialized bool
+	cpuCache      = make(map[string]cpuSnapshot) // Keyed by /proc/stat line name: "cpu", "cpu0", ...
+	cpuCacheMutex sync.Mutex
 )
 
-// getCPUMetrics reads detailed CPU metrics from /proc/stat
-// Returns percentages for usage, iowait, irq, and softirq
-func getCPUMetrics() (cpuMetrics, error) {
-	// Read /proc/stat for CPU metrics
-	data, err := os.ReadFile("/proc/stat")
-	if err != nil {
-		return cpuMetrics{}, err
-	}
+// parseProcStat parses the aggregate and per-core cpu lines of /proc/stat.
+// Format: cpu0 user nice system idle iowait irq softirq steal guest guest_nice
+// Older kernels omit the trailing columns, which are then zero.
+func parseProcStat(data string) (map[string]cpuSnapshot, error) {
+	snapshots := make(map[string]cpuSnapshot)
 
-	// Parse first line (cpu total)
-	var user, nice, system, idle, iowait, irq, softirq, steal uint64
-	_, err = fmt.Sscanf(string(data), "cpu %d %d %d %d %d %d %d %d",
-		&user, &nice, &system, &idle, &iowait, &irq, &softirq, &steal)
-	if err != nil {
-		return cpuMetrics{}, err
-	}
+	for _, line := range strings.Split(data, "\n") {
+		if !strings.HasPrefix(line, "cpu") {
+			continue
+		}
 
-	cpuCacheMutex.Lock()
-	defer cpuCacheMutex.Unlock()
+		fields := strings.Fields(line)
+		if len(fields) < 5 {
+			return nil, fmt.Errorf("%s: expected at least 4 counters, got %d", fields[0], len(fields)-1)
+		}
+
+		var values [10]uint64
+		for i, field := range fields[1:] {
+			if i >= len(values) {
+				break
+			}
+			value, err := strconv.ParseUint(field, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("%s: invalid counter: %w", fields[0], err)
+			}
+			values[i] = value
+		}
+
+		snapshots[fields[0]] = cpuSnapshot{
+			user:      values[0],
+			nice:      values[1],
+			system:    values[2],
+			idle:      values[3],
+			iowait:    values[4],
+			irq:       values[5],
+			softirq:   values[6],
+			steal:     values[7],
+			guest:     values[8],
+			guestNice: values[9],
+		}
+	}
 
-	// First reading, initialize cache
-	if !cpuInitialized {
-		cpuCache = cpuSnapshot{user, nice, system, idle, iowait, irq, softirq, steal}
-		cpuInitialized = true
-		return cpuMetrics{}, nil // Return zeros on first call
+	if _, exists := snapshots["cpu"]; !exists {
+		return nil, fmt.Errorf("aggregate cpu line not found")
 	}
 
+	return snapshots, nil
+}
+
+// cpuPercentages computes CPU percentages between two snapshots.
+// The result is stale when no tick elapsed or a counter went backwards,
+// e.g. after a CPU went offline and online again.
+func cpuPercentages(previous, current cpuSnapshot) cpuMetrics {
 	// Calculate deltas
-	userDelta := user - cpuCache.user
-	niceDelta := nice - cpuCache.nice
-	systemDelta := system - cpuCache.system
-	idleDelta := idle - cpuCache.idle
-	iowaitDelta := iowait - cpuCache.iowait
-	irqDelta := irq - cpuCache.irq
-	softirqDelta := softirq - cpuCache.softirq
-	stealDelta := steal - cpuCache.steal
+	var deltas counterDeltas
+	userDelta := deltas.delta(previous.user, current.user)
+	niceDelta := deltas.delta(previous.nice, current.nice)
+	systemDelta := deltas.delta(previous.system, current.system)
+	idleDelta := deltas.delta(previous.idle, current.idle)
+	irqDelta := deltas.delta(previous.irq, current.irq)
+	softirqDelta := deltas.delta(previous.softirq, current.softirq)
+	stealDelta := deltas.delta(previous.steal, current.steal)
+	guestDelta := deltas.delta(previous.guest, current.guest) + deltas.delta(previous.guestNice, current.guestNice)
+
+	// iowait is documented to decrease on some kernels, which is not a reset
+	iowait, _ := counterDelta(previous.iowait, current.iowait)
+	iowaitDelta := float64(iowait)
+
+	if deltas.reset {
+		return cpuMetrics{Stale: true}
+	}
 
+	// Guest time is already accounted in user and nice
 	totalDelta := userDelta + niceDelta + systemDelta + idleDelta + iowaitDelta + irqDelta + softirqDelta + stealDelta
 
-	// Update cache
-	cpuCache = cpuSnapshot{user, nice, system, idle, iowait, irq, softirq, steal}
-
 	if totalDelta == 0 {
-		return cpuMetrics{}, nil
+		return cpuMetrics{Stale: true}
 	}
 
 	// Calculate percentages
-	metrics := cpuMetrics{
-		Usage:   float64(userDelta+niceDelta+systemDelta) / float64(totalDelta) * 100.0,
-		IOWait:  float64(iowaitDelta) / float64(totalDelta) * 100.0,
-		IRQ:     float64(irqDelta) / float64(totalDelta) * 100.0,
-		SoftIRQ: float64(softirqDelta) / float64(totalDelta) * 100.0,
+	return cpuMetrics{
+		Usage:   (userDelta + niceDelta + systemDelta) / totalDelta * 100.0,
+		IOWait:  iowaitDelta / totalDelta * 100.0,
+		IRQ:     irqDelta / totalDelta * 100.0,
+		SoftIRQ: softirqDelta / totalDelta * 100.0,
+		Steal:   stealDelta / totalDelta * 100.0,
+		Guest:   guestDelta / totalDelta * 100.0,
+		Busy:    (userDelta + niceDelta + systemDelta + irqDelta + softirqDelta) / totalDelta * 100.0,
 	}
-
-	return metrics, nil
 }
 
-// collectCPUMetric runs as a goroutine to collect CPU metrics
-func collectCPUMetric() {
-	for {
-		metrics, err := getCPUMetrics()
-		if err != nil {
-			log.Printf("Error collecting CPU metrics: %v", err)
-			metrics = cpuMetrics{}
-		}
+// readCPUMetrics reads aggregate and per-core CPU metrics from /proc/stat.
+// Per-core metrics are keyed by core number. Cores seen for the first time
+// are stale until the next reading.
+func readCPUMetrics() (cpuMetrics, map[string]cpuMetrics, error) {
+	data, err := os.ReadFile(procPath("stat"))
+	if err != nil {
+		return cpuMetrics{}, nil, err
+	}
 
-		// Apply warmup factor if enabled
-		warmupFactor := 1.0
-		if config.Warmup.Enabled {
-			warmupFactor = getWarmupFactor()
-		}
+	snapshots, err := parseProcStat(string(data))
+	if err != nil {
+		return cpuMetrics{}, nil, err
+	}
 
-		// Calculate effective max values with warmup
-		effectiveMaxCPU := config.Thresholds.MaxCPU * warmupFactor
-		effectiveMaxIOWait := config.Thresholds.MaxIOWait * warmupFactor
-		effectiveMaxIRQ := config.Thresholds.MaxIRQ * warmupFactor
-		effectiveMaxSoftIRQ := config.Thresholds.MaxSoftIRQ * warmupFactor
+	cpuCacheMutex.Lock()
+	defer cpuCacheMutex.Unlock()
 
-		// Determine status for each metric
-		cpuStatus := "OK"
-		if metrics.Usage > effectiveMaxCPU {
-			cpuStatus = "KO"
+	var total cpuMetrics
+	cores := make(map[string]cpuMetrics)
+	for name, current := range snapshots {
+		metrics := cpuMetrics{Stale: true}
+		if previous, exists := cpuCache[name]; exists {
+			metrics = cpuPercentages(previous, current)
 		}
 
-		iowaitStatus := "OK"
-		if metrics.IOWait > effectiveMaxIOWait {
-			iowaitStatus = "KO"
+		if name == "cpu" {
+			total = metrics
+		} else {
+			cores[strings.TrimPrefix(name, "cpu")] = metrics
 		}
+	}
 
-		irqStatus := "OK"
-		if metrics.IRQ > effectiveMaxIRQ {
-			irqStatus = "KO"
-		}
+	// Update cache, dropping cores that went offline
+	cpuCache = snapshots
 
-		softirqStatus := "OK"
-		if metrics.SoftIRQ > effectiveMaxSoftIRQ {
-			softirqStatus = "KO"
-		}
+	return total, cores, nil
+}
 
-		// Update cache for all CPU metrics
-		cacheMutex.Lock()
-		metricCache["cpu_usage"] = MetricStatus{
-			Current: metrics.Usage,
-			Max:     effectiveMaxCPU,
-			Status:  cpuStatus,
-		}
-		metricCache["cpu_iowait"] = MetricStatus{
-			Current: metrics.IOWait,
-			Max:     effectiveMaxIOWait,
-			Status:  iowaitStatus,
-		}
-		metricCache["cpu_irq"] = MetricStatus{
-			Current: metrics.IRQ,
-			Max:     effectiveMaxIRQ,
-			Status:  irqStatus,
-		}
-		metricCache["cpu_softirq"] = MetricStatus{
-			Current: metrics.SoftIRQ,
-			Max:     effectiveMaxSoftIRQ,
-			Status:  softirqStatus,
-		}
-		cacheMutex.Unlock()
+// getCPUMetrics reads detailed CPU metrics from /proc/stat
+// Returns aggregate percentages for usage, iowait, irq, softirq, steal and guest
+func getCPUMetrics() (cpuMetrics, error) {
+	total, _, err := readCPUMetrics()
+	return total, err
+}
 
-		time.Sleep(2 * time.Second)
-	}
+// cpuCollector reports CPU usage, iowait, irq, softirq, steal and per-core percentages
+type cpuCollector struct{}
+
+// Name identifies the collector in logs
+func (c *cpuCollector) Name() string {
+	return "cpu"
+}
+
+// Interval is the delay between two CPU collections
+func (c *cpuCollector) Interval() time.Duration {
+	return collectorInterval(c.Name(), 2*time.Second)
 }
 
-// getWarmupFactor returns a factor between 0.0 and 1.0 based on elapsed time
-func getWarmupFactor() float64 {
-	elapsed := time.Since(config.startTime)
-	if elapsed >= config.Warmup.Duration {
-		return 1.0
+// Collect reads CPU metrics and returns one sample per aggregate percentage,
+// per-core usage and softirq, and the busiest core
+func (c *cpuCollector) Collect(ctx context.Context) ([]Sample, error) {
+	metrics, cores, err := readCPUMetrics()
+
+	// In cgroup mode, max_cpu applies to cgroup_cpu_usage instead of the host
+	cfg := currentConfig()
+	samples := []Sample{
+		{Name: "cpu_usage", Value: metrics.Usage, Max: cfg.Thresholds.MaxCPU, NoMax: cfg.Cgroup.Enabled, Stale: metrics.Stale},
+		{Name: "cpu_iowait", Value: metrics.IOWait, Max: cfg.Thresholds.MaxIOWait, Stale: metrics.Stale},
+		{Name: "cpu_irq", Value: metrics.IRQ, Max: cfg.Thresholds.MaxIRQ, Stale: metrics.Stale},
+		{Name: "cpu_softirq", Value: metrics.SoftIRQ, Max: cfg.Thresholds.MaxSoftIRQ, Stale: metrics.Stale},
+		{Name: "cpu_steal", Value: metrics.Steal, Max: cfg.Thresholds.MaxSteal, NoMax: cfg.Thresholds.MaxSteal <= 0, Stale: metrics.Stale},
+		{Name: "cpu_guest", Value: metrics.Guest, NoMax: true, Stale: metrics.Stale},
 	}
-	return elapsed.Seconds() / config.Warmup.Duration.Seconds()
+
+	// Per-core samples sorted by core number
+	coreNames := make([]string, 0, len(cores))
+	for core := range cores {
+		coreNames = append(coreNames, core)
+	}
+	sort.Slice(coreNames, func(i, j int) bool {
+		a, _ := strconv.Atoi(coreNames[i])
+		b, _ := strconv.Atoi(coreNames[j])
+		return a < b
+	})
+
+	// The busiest core is stale only when no core has valid percentages
+	maxBusy, maxSoftIRQ := 0.0, 0.0
+	coresStale := true
+	for _, core := range coreNames {
+		coreMetrics := cores[core]
+		labels := map[string]string{"cpu": core}
+		samples = append(samples,
+			Sample{Name: "cpu" + core + "_usage", Value: coreMetrics.Usage, NoMax: true, Stale: coreMetrics.Stale, Metric: "cpu_core_usage", Labels: labels},
+			Sample{Name: "cpu" + core + "_softirq", Value: coreMetrics.SoftIRQ, NoMax: true, Stale: coreMetrics.Stale, Metric: "cpu_core_softirq", Labels: labels},
+		)
+		if coreMetrics.Stale {
+			continue
+		}
+		coresStale = false
+		maxBusy = math.Max(maxBusy, coreMetrics.Busy)
+		maxSoftIRQ = math.Max(maxSoftIRQ, coreMetrics.SoftIRQ)
+	}
+
+	// A single core pegged by interrupts hides in the aggregate percentages
+	samples = append(samples,
+		Sample{Name: "cpu_max_core", Value: maxBusy, Max: cfg.Thresholds.MaxCoreUsage, NoMax: cfg.Thresholds.MaxCoreUsage <= 0, Stale: coresStale},
+		Sample{Name: "cpu_max_core_softirq", Value: maxSoftIRQ, Max: cfg.Thresholds.MaxCoreSoftIRQ, NoMax: cfg.Thresholds.MaxCoreSoftIRQ <= 0, Stale: coresStale},
+	)
+
+	// Without /proc/stat, no core is known and every sample is unknown
+	if err != nil {
+		failSamples(samples, err)
+	}
+
+	return samples, err
 }
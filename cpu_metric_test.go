@@ -1,45 +1,72 @@
 package main
 
 import (
+	"context"
+	"math"
 	"testing"
 	"time"
 )
 
 func TestGetCPUMetrics(t *testing.T) {
+	oldConfig := config
+	defer func() { config = oldConfig }()
+
 	// Reset CPU cache for clean test
 	cpuCacheMutex.Lock()
-	cpuInitialized = false
+	cpuCache = make(map[string]cpuSnapshot)
 	cpuCacheMutex.Unlock()
 
-	// First call should return zeros (no baseline)
+	// First call is stale (no baseline)
+	useFixture("idle")
 	metrics1, err := getCPUMetrics()
 	if err != nil {
 		t.Fatalf("getCPUMetrics() returned error: %v", err)
 	}
 
-	if metrics1.Usage != 0 || metrics1.IOWait != 0 || metrics1.IRQ != 0 || metrics1.SoftIRQ != 0 {
-		t.Errorf("getCPUMetrics() first call should return zeros, got %+v", metrics1)
+	if metrics1 != (cpuMetrics{Stale: true}) {
+		t.Errorf("getCPUMetrics() first call should be stale, got %+v", metrics1)
 	}
 
-	// Wait and call again to get actual deltas
-	time.Sleep(100 * time.Millisecond)
+	// 800 user, 100 system and 100 idle ticks since the idle snapshot
+	useFixture("busy")
 	metrics2, err := getCPUMetrics()
 	if err != nil {
 		t.Fatalf("getCPUMetrics() second call returned error: %v", err)
 	}
 
-	// Verify all metrics are within valid range
-	if metrics2.Usage < 0 || metrics2.Usage > 100 {
-		t.Errorf("CPU usage = %v, want value between 0 and 100", metrics2.Usage)
+	expected := cpuMetrics{Usage: 90, Busy: 90}
+	if metrics2 != expected {
+		t.Errorf("getCPUMetrics() = %+v, want %+v", metrics2, expected)
+	}
+
+	// cpu1 went offline and online again with fresh counters
+	useFixture("reset")
+	metrics3, err := getCPUMetrics()
+	if err != nil {
+		t.Fatalf("getCPUMetrics() third call returned error: %v", err)
+	}
+
+	if metrics3 != (cpuMetrics{Stale: true}) {
+		t.Errorf("getCPUMetrics() after a counter reset should be stale, got %+v", metrics3)
 	}
-	if metrics2.IOWait < 0 || metrics2.IOWait > 100 {
-		t.Errorf("IOWait = %v, want value between 0 and 100", metrics2.IOWait)
+}
+
+func TestCPUCollectorWithoutProcStat(t *testing.T) {
+	oldConfig := config
+	defer func() { config = oldConfig }()
+	config.Paths.Proc = t.TempDir()
+
+	samples, err := (&cpuCollector{}).Collect(context.Background())
+	if err == nil {
+		t.Fatal("cpuCollector.Collect() returned no error without /proc/stat")
 	}
-	if metrics2.IRQ < 0 || metrics2.IRQ > 100 {
-		t.Errorf("IRQ = %v, want value between 0 and 100", metrics2.IRQ)
+	if len(samples) == 0 {
+		t.Fatal("cpuCollector.Collect() returned no samples")
 	}
-	if metrics2.SoftIRQ < 0 || metrics2.SoftIRQ > 100 {
-		t.Errorf("SoftIRQ = %v, want value between 0 and 100", metrics2.SoftIRQ)
+	for _, sample := range samples {
+		if sample.Err == nil {
+			t.Errorf("%s has no error, want the /proc/stat read error", sample.Name)
+		}
 	}
 }
 
@@ -106,7 +133,7 @@ func TestCollectCPUMetricUpdatesCache(t *testing.T) {
 
 	// Reset CPU cache
 	cpuCacheMutex.Lock()
-	cpuInitialized = false
+	cpuCache = make(map[string]cpuSnapshot)
 	cpuCacheMutex.Unlock()
 
 	// Clear cache
@@ -115,38 +142,16 @@ func TestCollectCPUMetricUpdatesCache(t *testing.T) {
 	cacheMutex.Unlock()
 
 	// First call to initialize
-	metrics, _ := getCPUMetrics()
+	collector := &cpuCollector{}
+	collector.Collect(context.Background())
 
 	// Wait and collect again
 	time.Sleep(100 * time.Millisecond)
-	metrics, err := getCPUMetrics()
+	samples, err := collector.Collect(context.Background())
 	if err != nil {
-		metrics = cpuMetrics{}
+		t.Fatalf("cpuCollector.Collect() returned error: %v", err)
 	}
-
-	// Simulate what collectCPUMetric does
-	cacheMutex.Lock()
-	metricCache["cpu_usage"] = MetricStatus{
-		Current: metrics.Usage,
-		Max:     config.Thresholds.MaxCPU,
-		Status:  "OK",
-	}
-	metricCache["cpu_iowait"] = MetricStatus{
-		Current: metrics.IOWait,
-		Max:     config.Thresholds.MaxIOWait,
-		Status:  "OK",
-	}
-	metricCache["cpu_irq"] = MetricStatus{
-		Current: metrics.IRQ,
-		Max:     config.Thresholds.MaxIRQ,
-		Status:  "OK",
-	}
-	metricCache["cpu_softirq"] = MetricStatus{
-		Current: metrics.SoftIRQ,
-		Max:     config.Thresholds.MaxSoftIRQ,
-		Status:  "OK",
-	}
-	cacheMutex.Unlock()
+	publishSamples(samples)
 
 	// Verify all CPU metrics were updated
 	cacheMutex.RLock()
@@ -186,3 +191,153 @@ func TestCollectCPUMetricUpdatesCache(t *testing.T) {
 		t.Errorf("CPU softirq max = %v, want 10.0", softirqMetric.Max)
 	}
 }
+
+func TestCPUCollectorFixtureGoesKO(t *testing.T) {
+	oldConfig := config
+	config = Config{
+		startTime: time.Now(),
+	}
+	config.Thresholds.MaxCPU = 80.0
+	config.Thresholds.MaxCoreUsage = 95.0
+	defer func() { config = oldConfig }()
+
+	cpuCacheMutex.Lock()
+	cpuCache = make(map[string]cpuSnapshot)
+	cpuCacheMutex.Unlock()
+
+	cacheMutex.Lock()
+	metricCache = make(map[string]MetricStatus)
+	cacheMutex.Unlock()
+
+	collector := &cpuCollector{}
+	for _, step := range []struct {
+		fixture        string
+		wantUsage      float64
+		wantStatus     string
+		wantCore       float64
+		wantCoreStatus string
+	}{
+		{fixture: "idle", wantStatus: "STALE", wantCoreStatus: "STALE"},
+		{fixture: "busy", wantUsage: 90, wantStatus: "KO", wantCore: 90, wantCoreStatus: "OK"},
+		// cpu1 came back online with fresh counters, only cpu0 is valid
+		{fixture: "reset", wantStatus: "STALE", wantCore: 250.0 / 300.0 * 100.0, wantCoreStatus: "OK"},
+	} {
+		useFixture(step.fixture)
+		samples, err := collector.Collect(context.Background())
+		if err != nil {
+			t.Fatalf("%s: cpuCollector.Collect() returned error: %v", step.fixture, err)
+		}
+		publishSamples(samples)
+
+		cacheMutex.RLock()
+		usage := metricCache["cpu_usage"]
+		core := metricCache["cpu_max_core"]
+		cacheMutex.RUnlock()
+
+		if usage.Current != step.wantUsage || usage.Status != step.wantStatus {
+			t.Errorf("%s: cpu_usage = %v (%s), want %v (%s)", step.fixture, usage.Current, usage.Status, step.wantUsage, step.wantStatus)
+		}
+		if math.Abs(core.Current-step.wantCore) > 1e-9 || core.Status != step.wantCoreStatus {
+			t.Errorf("%s: busiest core = %v (%s), want %v (%s)", step.fixture, core.Current, core.Status, step.wantCore, step.wantCoreStatus)
+		}
+	}
+}
+
+func TestParseProcStat(t *testing.T) {
+	tests := []struct {
+		name     string
+		data     string
+		expected map[string]cpuSnapshot
+		wantErr  bool
+	}{
+		{
+			name: "aggregate and cores",
+			data: "cpu  100 10 50 800 20 5 15 30 40 2\ncpu0 50 5 25 400 10 3 10 15 20 1\ncpu1 50 5 25 400 10 2 5 15 20 1\nintr 12345\n",
+			expected: map[string]cpuSnapshot{
+				"cpu":  {user: 100, nice: 10, system: 50, idle: 800, iowait: 20, irq: 5, softirq: 15, steal: 30, guest: 40, guestNice: 2},
+				"cpu0": {user: 50, nice: 5, system: 25, idle: 400, iowait: 10, irq: 3, softirq: 10, steal: 15, guest: 20, guestNice: 1},
+				"cpu1": {user: 50, nice: 5, system: 25, idle: 400, iowait: 10, irq: 2, softirq: 5, steal: 15, guest: 20, guestNice: 1},
+			},
+		},
+		{
+			name: "older kernel without steal and guest",
+			data: "cpu  100 10 50 800 20 5 15\n",
+			expected: map[string]cpuSnapshot{
+				"cpu": {user: 100, nice: 10, system: 50, idle: 800, iowait: 20, irq: 5, softirq: 15},
+			},
+		},
+		{
+			name:    "missing aggregate line",
+			data:    "cpu0 50 5 25 400 10 3 10\n",
+			wantErr: true,
+		},
+		{
+			name:    "invalid counter",
+			data:    "cpu  100 abc 50 800\n",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			snapshots, err := parseProcStat(tt.data)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("parseProcStat() expected error, got %+v", snapshots)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseProcStat() returned error: %v", err)
+			}
+			if len(snapshots) != len(tt.expected) {
+				t.Fatalf("parseProcStat() returned %d lines, expected %d", len(snapshots), len(tt.expected))
+			}
+			for name, expected := range tt.expected {
+				if snapshots[name] != expected {
+					t.Errorf("parseProcStat()[%s] = %+v, expected %+v", name, snapshots[name], expected)
+				}
+			}
+		})
+	}
+}
+
+func TestCPUPercentages(t *testing.T) {
+	previous := cpuSnapshot{user: 100, nice: 0, system: 100, idle: 700, iowait: 50, irq: 10, softirq: 20, steal: 20}
+	current := cpuSnapshot{user: 150, nice: 10, system: 140, idle: 850, iowait: 70, irq: 20, softirq: 40, steal: 40, guest: 10, guestNice: 5}
+
+	// Total delta: 50 + 10 + 40 + 150 + 20 + 10 + 20 + 20 = 320
+	metrics := cpuPercentages(previous, current)
+
+	expected := cpuMetrics{
+		Usage:   100.0 / 320 * 100,
+		IOWait:  20.0 / 320 * 100,
+		IRQ:     10.0 / 320 * 100,
+		SoftIRQ: 20.0 / 320 * 100,
+		Steal:   20.0 / 320 * 100,
+		Guest:   15.0 / 320 * 100,
+		Busy:    130.0 / 320 * 100,
+	}
+	if metrics != expected {
+		t.Errorf("cpuPercentages() = %+v, expected %+v", metrics, expected)
+	}
+
+	if stale := cpuPercentages(current, current); stale != (cpuMetrics{Stale: true}) {
+		t.Errorf("cpuPercentages() with no elapsed time = %+v, expected stale", stale)
+	}
+
+	// A reset counter must not produce a huge bogus percentage
+	reset := current
+	reset.user = 5
+	if stale := cpuPercentages(current, reset); stale != (cpuMetrics{Stale: true}) {
+		t.Errorf("cpuPercentages() after a counter reset = %+v, expected stale", stale)
+	}
+
+	// iowait going backwards is a known kernel quirk, not a reset
+	quirk := current
+	quirk.idle += 100
+	quirk.iowait -= 10
+	if metrics := cpuPercentages(current, quirk); metrics.Stale || metrics.IOWait != 0 || metrics.Usage != 0 {
+		t.Errorf("cpuPercentages() with decreasing iowait = %+v, expected idle percentages", metrics)
+	}
+}
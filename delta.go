@@ -0,0 +1,42 @@
+package main
+
+// counterDelta returns the increase of a cumulative kernel counter between two
+// readings. ok is false when the counter went backwards: it was reset, e.g. by
+// a driver reload or a re-created interface, or it wrapped at 32 bits.
+func counterDelta(previous, current uint64) (uint64, bool) {
+	if current < previous {
+		return 0, false
+	}
+	return current - previous, true
+}
+
+// counterDeltas computes the increases of the counters of one reading and
+// remembers whether any of them was reset. A reading with a reset counter
+// is poisoned: it only becomes the baseline for the next one.
+type counterDeltas struct {
+	elapsed float64 // Seconds between the two readings
+	reset   bool    // A counter went backwards
+}
+
+// delta returns the increase of a counter, 0 when it was reset
+func (d *counterDeltas) delta(previous, current uint64) float64 {
+	delta, ok := counterDelta(previous, current)
+	if !ok {
+		d.reset = true
+	}
+	return float64(delta)
+}
+
+// rate returns the per-second increase of a counter, 0 when it was reset
+func (d *counterDeltas) rate(previous, current uint64) float64 {
+	if d.elapsed <= 0 {
+		return 0
+	}
+	return d.delta(previous, current) / d.elapsed
+}
+
+// stale reports whether the deltas computed so far are unusable,
+// because a counter was reset or no time elapsed between the readings
+func (d *counterDeltas) stale() bool {
+	return d.reset || d.elapsed <= 0
+}
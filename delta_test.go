@@ -0,0 +1,42 @@
+package main
+
+import "testing"
+
+func TestCounterDeltas(t *testing.T) {
+	tests := []struct {
+		name      string
+		elapsed   float64
+		previous  uint64
+		current   uint64
+		wantRate  float64
+		wantStale bool
+	}{
+		{name: "increasing counter", elapsed: 2, previous: 1000, current: 3000, wantRate: 1000},
+		{name: "unchanged counter", elapsed: 2, previous: 1000, current: 1000, wantRate: 0},
+		{name: "interface re-created", elapsed: 2, previous: 5000000, current: 1200, wantStale: true},
+		{name: "32-bit counter wrap", elapsed: 2, previous: 4294967000, current: 200, wantStale: true},
+		{name: "no time elapsed", elapsed: 0, previous: 1000, current: 3000, wantStale: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			deltas := counterDeltas{elapsed: tt.elapsed}
+			rate := deltas.rate(tt.previous, tt.current)
+			if deltas.stale() != tt.wantStale {
+				t.Fatalf("stale() = %v, want %v", deltas.stale(), tt.wantStale)
+			}
+			if rate != tt.wantRate {
+				t.Errorf("rate() = %v, want %v", rate, tt.wantRate)
+			}
+		})
+	}
+
+	// One reset counter poisons the whole reading
+	deltas := counterDeltas{elapsed: 1}
+	deltas.rate(10, 20)
+	deltas.rate(30, 5)
+	deltas.rate(10, 20)
+	if !deltas.stale() {
+		t.Error("stale() = false after a reset counter, want true")
+	}
+}
@@ -1,69 +1,161 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
-	"log"
+	"sync"
 	"syscall"
 	"time"
 )
 
-// getDiskUsage reads disk usage for a given path using statfs
-// Returns percentage of disk space used
-func getDiskUsage(path string) (float64, error) {
-	var stat syscall.Statfs_t
-	err := syscall.Statfs(path, &stat)
-	if err != nil {
-		return 0, err
-	}
+// stRdonly is the statfs flag set on read-only mounts
+const stRdonly = 0x1
+
+// diskStats holds the filesystem state of a monitored path
+type diskStats struct {
+	Usage    float64 // Percentage of disk space used
+	Inodes   float64 // Percentage of inodes used
+	ReadOnly bool    // Filesystem is mounted read-only
+}
+
+// diskStatsFromStatfs computes space and inode usage from a statfs result.
+// Filesystems without a fixed inode table report zero inodes and 0% usage.
+func diskStatsFromStatfs(stat syscall.Statfs_t) diskStats {
+	var stats diskStats
 
 	// Calculate disk usage
 	total := stat.Blocks * uint64(stat.Bsize)
 	available := stat.Bavail * uint64(stat.Bsize)
 	used := total - available
+	if total > 0 {
+		stats.Usage = float64(used) / float64(total) * 100.0
+	}
+
+	// Calculate inode usage
+	if stat.Files > 0 {
+		stats.Inodes = float64(stat.Files-stat.Ffree) / float64(stat.Files) * 100.0
+	}
+
+	stats.ReadOnly = stat.Flags&stRdonly != 0
+
+	return stats
+}
+
+// getDiskStats reads disk space, inode usage and mount flags for a given path using statfs.
+// A statfs blocked on a hung network filesystem is abandoned when ctx is done.
+func getDiskStats(ctx context.Context, path string) (diskStats, error) {
+	var stat syscall.Statfs_t
+	err := callWithContext(ctx, "statfs "+path, func() error {
+		return syscall.Statfs(path, &stat)
+	})
+	if err != nil {
+		return diskStats{}, err
+	}
+
+	return diskStatsFromStatfs(stat), nil
+}
 
-	diskPercent := float64(used) / float64(total) * 100.0
+// getDiskUsage reads disk usage for a given path using statfs
+// Returns percentage of disk space used
+func getDiskUsage(path string) (float64, error) {
+	stats, err := getDiskStats(context.Background(), path)
+	return stats.Usage, err
+}
+
+// forEachDiskPath calls call concurrently for every distinct path and waits for
+// all of them, so a call blocked on a hung mount until its context is done does
+// not leave the other paths without time
+func forEachDiskPath(paths []DiskPath, call func(path string)) {
+	var wg sync.WaitGroup
+	seen := make(map[string]bool)
+	for _, disk := range paths {
+		if seen[disk.Path] {
+			continue
+		}
+		seen[disk.Path] = true
 
-	return diskPercent, nil
+		wg.Add(1)
+		go func(path string) {
+			defer wg.Done()
+			call(path)
+		}(disk.Path)
+	}
+	wg.Wait()
 }
 
-// collectDiskMetric runs as a goroutine to collect disk metrics
-// Monitors all paths specified in config.DiskPaths against config.MaxDisk
-func collectDiskMetric() {
-	for {
-		// Apply warmup factor if enabled
-		effectiveMax := config.Thresholds.MaxDisk
-		if config.Warmup.Enabled {
-			warmupFactor := getWarmupFactor()
-			effectiveMax = config.Thresholds.MaxDisk * warmupFactor
+// diskCollector reports disk space, inode usage and read-only mounts
+// for every path in config.Monitoring.DiskPaths
+type diskCollector struct{}
+
+// Name identifies the collector in logs
+func (c *diskCollector) Name() string {
+	return "disk"
+}
+
+// Interval is the delay between two disk collections
+func (c *diskCollector) Interval() time.Duration {
+	return collectorInterval(c.Name(), 5*time.Second) // Check disk less frequently
+}
+
+// Collect returns space, inode and read-only samples per monitored path.
+// Space and inodes are checked against the path's max_disk and max_inodes
+// or config.Thresholds.MaxDisk and MaxInodes; a read-only mount is always KO.
+func (c *diskCollector) Collect(ctx context.Context) ([]Sample, error) {
+	cfg := currentConfig()
+	var samples []Sample
+	var errs []error
+
+	var mu sync.Mutex
+	pathStats := make(map[string]diskStats)
+	pathErrors := make(map[string]error)
+	forEachDiskPath(cfg.Monitoring.DiskPaths, func(path string) {
+		stats, err := getDiskStats(ctx, path)
+		mu.Lock()
+		pathStats[path], pathErrors[path] = stats, err
+		mu.Unlock()
+	})
+
+	for _, disk := range cfg.Monitoring.DiskPaths {
+		path := disk.Path
+		stats, err := pathStats[path], pathErrors[path]
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", path, err))
+		}
+
+		// Path-specific threshold overrides, 0 disables the limit
+		maxDisk := pathThreshold(disk.MaxDisk, cfg.Thresholds.MaxDisk)
+		maxInodes := pathThreshold(disk.MaxInodes, cfg.Thresholds.MaxInodes)
+
+		readOnly := 0.0
+		if stats.ReadOnly {
+			readOnly = 1
 		}
 
-		// Check each disk path
-		for _, path := range config.Monitoring.DiskPaths {
-			diskUsage, err := getDiskUsage(path)
-			if err != nil {
-				log.Printf("Error collecting disk metric for %s: %v", path, err)
-				diskUsage = 0
-			}
-
-			// Determine status
-			status := "OK"
-			if diskUsage > effectiveMax {
-				status = "KO"
-			}
-
-			// Update cache with path-specific metric name
-			metricName := fmt.Sprintf("disk_%s", sanitizePath(path))
-			cacheMutex.Lock()
-			metricCache[metricName] = MetricStatus{
-				Current: diskUsage,
-				Max:     effectiveMax,
-				Status:  status,
-			}
-			cacheMutex.Unlock()
+		// Path-specific metric names
+		name := sanitizePath(path)
+		labels := map[string]string{"path": path}
+		pathSamples := []Sample{
+			{Name: fmt.Sprintf("disk_%s", name), Value: stats.Usage, Max: maxDisk, NoMax: maxDisk <= 0, Metric: "disk", Labels: labels},
+			{Name: fmt.Sprintf("disk_inodes_%s", name), Value: stats.Inodes, Max: maxInodes, NoMax: maxInodes <= 0, Metric: "disk_inodes", Labels: labels},
+			{Name: fmt.Sprintf("disk_readonly_%s", name), Value: readOnly, Max: 0, NoMax: disk.ExpectReadOnly, Metric: "disk_readonly", Labels: labels},
+		}
+		// A missing mount point must not look like an empty disk
+		if err != nil {
+			failSamples(pathSamples, err)
 		}
+		samples = append(samples, pathSamples...)
+	}
+
+	return samples, errors.Join(errs...)
+}
 
-		time.Sleep(5 * time.Second) // Check disk less frequently
+// pathThreshold returns the override of a path threshold when set, otherwise the global threshold
+func pathThreshold(override *float64, global float64) float64 {
+	if override != nil {
+		return *override
 	}
+	return global
 }
 
 // sanitizePath converts a filesystem path to a metric-friendly name
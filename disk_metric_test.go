@@ -1,6 +1,10 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"sync"
+	"syscall"
 	"testing"
 	"time"
 )
@@ -111,7 +115,7 @@ func TestCollectDiskMetricUpdatesCache(t *testing.T) {
 	config.Warmup.Enabled = false
 	config.Warmup.Duration = 60 * time.Second
 	config.Thresholds.MaxDisk = 95.0
-	config.Monitoring.DiskPaths = []string{"/", "/tmp"}
+	config.Monitoring.DiskPaths = []DiskPath{{Path: "/"}, {Path: "/tmp", MaxDisk: float64Ptr(99.0)}}
 	defer func() { config = oldConfig }()
 
 	// Clear cache
@@ -120,27 +124,11 @@ func TestCollectDiskMetricUpdatesCache(t *testing.T) {
 	cacheMutex.Unlock()
 
 	// Run one iteration of metric collection
-	effectiveMax := config.Thresholds.MaxDisk
-	for _, path := range config.Monitoring.DiskPaths {
-		diskUsage, err := getDiskUsage(path)
-		if err != nil {
-			diskUsage = 0
-		}
-
-		status := "OK"
-		if diskUsage > effectiveMax {
-			status = "KO"
-		}
-
-		metricName := "disk_" + sanitizePath(path)
-		cacheMutex.Lock()
-		metricCache[metricName] = MetricStatus{
-			Current: diskUsage,
-			Max:     effectiveMax,
-			Status:  status,
-		}
-		cacheMutex.Unlock()
+	samples, err := (&diskCollector{}).Collect(context.Background())
+	if err != nil {
+		t.Fatalf("diskCollector.Collect() returned error: %v", err)
 	}
+	publishSamples(samples)
 
 	// Verify cache was updated for both paths
 	cacheMutex.RLock()
@@ -168,7 +156,199 @@ func TestCollectDiskMetricUpdatesCache(t *testing.T) {
 		t.Errorf("Root disk metric max = %v, want 95.0", rootMetric.Max)
 	}
 
+	if tmpMetric.Max != 99.0 {
+		t.Errorf("Tmp disk metric max = %v, want 99.0 (path override)", tmpMetric.Max)
+	}
+
 	if rootMetric.Status != "OK" && rootMetric.Status != "KO" {
 		t.Errorf("Root disk metric status = %v, want OK or KO", rootMetric.Status)
 	}
+
+	cacheMutex.RLock()
+	inodesMetric, inodesExists := metricCache["disk_inodes_root"]
+	_, readOnlyExists := metricCache["disk_readonly_root"]
+	cacheMutex.RUnlock()
+
+	if !inodesExists || !readOnlyExists {
+		t.Fatal("Inode or read-only metric for root not found in cache")
+	}
+
+	if inodesMetric.Current < 0 || inodesMetric.Current > 100 {
+		t.Errorf("Root inode metric current = %v, want value between 0 and 100", inodesMetric.Current)
+	}
+}
+
+func TestDiskCollectorMissingPath(t *testing.T) {
+	oldConfig := config
+	config = Config{
+		startTime: time.Now(),
+	}
+	config.Thresholds.MaxDisk = 95.0
+	config.Monitoring.DiskPaths = []DiskPath{{Path: "/nonexistent-probe-mount"}}
+	defer func() { config = oldConfig }()
+
+	cacheMutex.Lock()
+	metricCache = make(map[string]MetricStatus)
+	cacheMutex.Unlock()
+
+	samples, err := (&diskCollector{}).Collect(context.Background())
+	if err == nil {
+		t.Fatal("diskCollector.Collect() returned no error for a missing path")
+	}
+	publishSamples(samples)
+
+	// A missing mount point must not look like an empty disk
+	metrics := snapshotMetrics()
+	for _, name := range []string{"disk_nonexistent-probe-mount", "disk_inodes_nonexistent-probe-mount", "disk_readonly_nonexistent-probe-mount"} {
+		metric := metrics[name]
+		if metric.Status != "UNKNOWN" || metric.Error == "" {
+			t.Errorf("%s = %+v, want UNKNOWN with an error", name, metric)
+		}
+	}
+	if status := overallStatus(metrics); status != "KO" {
+		t.Errorf("overallStatus() = %v, want KO", status)
+	}
+}
+
+func TestDiskStatsFromStatfs(t *testing.T) {
+	tests := []struct {
+		name     string
+		stat     syscall.Statfs_t
+		expected diskStats
+	}{
+		{
+			name:     "space and inodes",
+			stat:     syscall.Statfs_t{Bsize: 4096, Blocks: 1000, Bavail: 250, Files: 200, Ffree: 20},
+			expected: diskStats{Usage: 75, Inodes: 90},
+		},
+		{
+			name:     "no inode table",
+			stat:     syscall.Statfs_t{Bsize: 4096, Blocks: 1000, Bavail: 500},
+			expected: diskStats{Usage: 50},
+		},
+		{
+			name:     "read-only mount",
+			stat:     syscall.Statfs_t{Bsize: 4096, Blocks: 1000, Bavail: 1000, Files: 100, Ffree: 100, Flags: stRdonly},
+			expected: diskStats{ReadOnly: true},
+		},
+		{
+			name:     "empty filesystem",
+			stat:     syscall.Statfs_t{},
+			expected: diskStats{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := diskStatsFromStatfs(tt.stat)
+			if got != tt.expected {
+				t.Errorf("diskStatsFromStatfs() = %+v, want %+v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestForEachDiskPathHungMount(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	// /mnt/nfs blocks until the collection times out, / answers after it was called
+	var mu sync.Mutex
+	calls := make(map[string]int)
+	pathErrors := make(map[string]error)
+	paths := []DiskPath{{Path: "/mnt/nfs"}, {Path: "/"}, {Path: "/"}}
+	forEachDiskPath(paths, func(path string) {
+		err := callWithContext(ctx, "statfs "+path, func() error {
+			if path == "/mnt/nfs" {
+				<-release
+			}
+			return nil
+		})
+		mu.Lock()
+		calls[path]++
+		pathErrors[path] = err
+		mu.Unlock()
+	})
+
+	if err := pathErrors["/mnt/nfs"]; !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("/mnt/nfs error = %v, want deadline exceeded", err)
+	}
+	if err := pathErrors["/"]; err != nil {
+		t.Errorf("/ error = %v, want nil", err)
+	}
+	if calls["/"] != 1 {
+		t.Errorf("/ called %d times, want 1", calls["/"])
+	}
+}
+
+func TestDiskPathOverrideDisablesLimit(t *testing.T) {
+	oldConfig := config
+	defer func() { config = oldConfig }()
+
+	config = Config{startTime: time.Now()}
+	config.Thresholds.MaxDisk = 0.0001
+	config.Thresholds.MaxInodes = 0.0001
+	config.Monitoring.DiskPaths = []DiskPath{{Path: "/", MaxDisk: float64Ptr(0), MaxInodes: float64Ptr(0)}}
+
+	cacheMutex.Lock()
+	metricCache = make(map[string]MetricStatus)
+	cacheMutex.Unlock()
+
+	samples, err := (&diskCollector{}).Collect(context.Background())
+	if err != nil {
+		t.Fatalf("diskCollector.Collect() returned error: %v", err)
+	}
+	publishSamples(samples)
+
+	metrics := snapshotMetrics()
+	for _, name := range []string{"disk_root", "disk_inodes_root"} {
+		if metric := metrics[name]; metric.Status != "OK" || metric.Max != 0 {
+			t.Errorf("%s = %+v, want OK without a limit", name, metric)
+		}
+	}
+}
+
+func TestDiskReadOnlyExpected(t *testing.T) {
+	oldConfig := config
+	defer func() { config = oldConfig }()
+
+	tests := []struct {
+		name           string
+		expectReadOnly bool
+		wantStatus     string
+	}{
+		{name: "unexpected read-only mount", expectReadOnly: false, wantStatus: "KO"},
+		{name: "read-only by design", expectReadOnly: true, wantStatus: "OK"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config = Config{startTime: time.Now()}
+			config.Monitoring.DiskPaths = []DiskPath{{Path: "/", ExpectReadOnly: tt.expectReadOnly}}
+
+			cacheMutex.Lock()
+			metricCache = make(map[string]MetricStatus)
+			cacheMutex.Unlock()
+
+			samples, err := (&diskCollector{}).Collect(context.Background())
+			if err != nil {
+				t.Fatalf("diskCollector.Collect() returned error: %v", err)
+			}
+
+			// Report / as read-only whatever the test host mounts
+			for i := range samples {
+				if samples[i].Name == "disk_readonly_root" {
+					samples[i].Value = 1
+				}
+			}
+			publishSamples(samples)
+
+			if metric := snapshotMetrics()["disk_readonly_root"]; metric.Status != tt.wantStatus {
+				t.Errorf("disk_readonly_root status = %v, want %v", metric.Status, tt.wantStatus)
+			}
+		})
+	}
 }
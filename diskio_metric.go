@@ -0,0 +1,275 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// sectorSize is the unit of the sector counters in /proc/diskstats, regardless of the device
+const sectorSize = 512
+
+// blockDeviceCounters holds the cumulative counters of a /proc/diskstats line
+type blockDeviceCounters struct {
+	name           string
+	reads          uint64 // Reads completed
+	sectorsRead    uint64
+	readTicks      uint64 // Milliseconds spent reading
+	writes         uint64 // Writes completed
+	sectorsWritten uint64
+	writeTicks     uint64 // Milliseconds spent writing
+	ioTicks        uint64 // Milliseconds spent doing I/O
+}
+
+// diskIORates holds I/O rates of a block device between two readings
+type diskIORates struct {
+	ReadIOPS   float64 // Reads completed per second
+	WriteIOPS  float64 // Writes completed per second
+	ReadBytes  float64 // Bytes read per second
+	WriteBytes float64 // Bytes written per second
+	Await      float64 // Average milliseconds per completed request
+	Util       float64 // Percentage of time the device was busy
+	Stale      bool    // No valid rates: first reading or counters reset
+}
+
+// diskIOSnapshot stores previous device counters for delta calculation
+type diskIOSnapshot struct {
+	counters  blockDeviceCounters
+	timestamp time.Time
+}
+
+var (
+	diskIOCache      = make(map[string]diskIOSnapshot) // Keyed by "major:minor"
+	diskIOCacheMutex sync.Mutex
+)
+
+// parseDiskStats parses the content of /proc/diskstats into counters keyed by "major:minor".
+// Format: major minor name reads reads_merged sectors_read read_ms writes writes_merged
+// sectors_written write_ms in_flight io_ms weighted_io_ms ...
+func parseDiskStats(data string) (map[string]blockDeviceCounters, error) {
+	devices := make(map[string]blockDeviceCounters)
+
+	for i, line := range strings.Split(data, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) < 14 {
+			return nil, fmt.Errorf("line %d: expected at least 14 fields, got %d", i+1, len(fields))
+		}
+
+		var values [11]uint64
+		for j := range values {
+			value, err := strconv.ParseUint(fields[j+3], 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: invalid counter: %w", i+1, err)
+			}
+			values[j] = value
+		}
+
+		devices[fields[0]+":"+fields[1]] = blockDeviceCounters{
+			name:           fields[2],
+			reads:          values[0],
+			sectorsRead:    values[2],
+			readTicks:      values[3],
+			writes:         values[4],
+			sectorsWritten: values[6],
+			writeTicks:     values[7],
+			ioTicks:        values[9],
+		}
+	}
+
+	return devices, nil
+}
+
+// diskIODelta computes I/O rates between two readings taken elapsed seconds apart.
+// The rates are stale when a counter went backwards, e.g. after the device was
+// removed and added again, or when no time elapsed.
+func diskIODelta(previous, current blockDeviceCounters, elapsed float64) diskIORates {
+	deltas := counterDeltas{elapsed: elapsed}
+	reads := deltas.delta(previous.reads, current.reads)
+	writes := deltas.delta(previous.writes, current.writes)
+	ticks := deltas.delta(previous.readTicks, current.readTicks) + deltas.delta(previous.writeTicks, current.writeTicks)
+
+	readBytes := deltas.rate(previous.sectorsRead, current.sectorsRead) * sectorSize
+	writeBytes := deltas.rate(previous.sectorsWritten, current.sectorsWritten) * sectorSize
+	busy := deltas.rate(previous.ioTicks, current.ioTicks)
+	if deltas.stale() {
+		return diskIORates{Stale: true}
+	}
+
+	rates := diskIORates{
+		ReadIOPS:   reads / elapsed,
+		WriteIOPS:  writes / elapsed,
+		ReadBytes:  readBytes,
+		WriteBytes: writeBytes,
+		Util:       busy / 1000 * 100.0,
+	}
+	if reads+writes > 0 {
+		rates.Await = ticks / (reads + writes)
+	}
+	if rates.Util > 100 {
+		// io_ticks is updated lazily and can run ahead of the wall clock
+		rates.Util = 100
+	}
+
+	return rates
+}
+
+// deviceNumber returns the "major:minor" number of the device holding path
+func deviceNumber(ctx context.Context, path string) (string, error) {
+	var stat syscall.Stat_t
+	err := callWithContext(ctx, "stat "+path, func() error {
+		return syscall.Stat(path, &stat)
+	})
+	if err != nil {
+		return "", err
+	}
+
+	dev := uint64(stat.Dev)
+	major := ((dev >> 8) & 0xfff) | ((dev >> 32) &^ 0xfff)
+	minor := (dev & 0xff) | ((dev >> 12) &^ 0xff)
+	return fmt.Sprintf("%d:%d", major, minor), nil
+}
+
+// getDiskIORates reads /proc/diskstats and returns rates for the given devices.
+// Devices seen for the first time are stale until the next reading.
+func getDiskIORates(devices []string) (map[string]diskIORates, map[string]string, error) {
+	data, err := os.ReadFile(procPath("diskstats"))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	counters, err := parseDiskStats(string(data))
+	if err != nil {
+		return nil, nil, err
+	}
+	currentTime := time.Now()
+
+	diskIOCacheMutex.Lock()
+	defer diskIOCacheMutex.Unlock()
+
+	rates := make(map[string]diskIORates)
+	names := make(map[string]string)
+	snapshots := make(map[string]diskIOSnapshot)
+	for _, device := range devices {
+		current, exists := counters[device]
+		if !exists {
+			continue
+		}
+		names[device] = current.name
+
+		if snapshot, exists := diskIOCache[device]; exists {
+			rates[device] = diskIODelta(snapshot.counters, current, currentTime.Sub(snapshot.timestamp).Seconds())
+		} else {
+			rates[device] = diskIORates{Stale: true}
+		}
+		snapshots[device] = diskIOSnapshot{counters: current, timestamp: currentTime}
+	}
+
+	// Update cache, dropping devices no longer monitored
+	diskIOCache = snapshots
+
+	return rates, names, nil
+}
+
+// diskIOCollector reports throughput, latency and utilisation of the block
+// devices backing config.Monitoring.DiskPaths
+type diskIOCollector struct{}
+
+// Name identifies the collector in logs
+func (c *diskIOCollector) Name() string {
+	return "diskio"
+}
+
+// Interval is the delay between two disk I/O collections
+func (c *diskIOCollector) Interval() time.Duration {
+	return collectorInterval(c.Name(), 2*time.Second)
+}
+
+// Collect returns IOPS, bytes/sec, await and util samples per monitored path.
+// Util and await are checked against config.Thresholds.MaxDiskUtil and MaxDiskAwait.
+// Paths not backed by a block device (tmpfs, overlay, network filesystems) are reported as unsupported.
+func (c *diskIOCollector) Collect(ctx context.Context) ([]Sample, error) {
+	cfg := currentConfig()
+	var samples []Sample
+	var errs []error
+
+	var mu sync.Mutex
+	pathDevices := make(map[string]string)
+	pathErrors := make(map[string]error)
+	forEachDiskPath(cfg.Monitoring.DiskPaths, func(path string) {
+		device, err := deviceNumber(ctx, path)
+		mu.Lock()
+		defer mu.Unlock()
+		if err != nil {
+			pathErrors[path] = err
+			return
+		}
+		pathDevices[path] = device
+	})
+
+	var devices []string
+	for _, disk := range cfg.Monitoring.DiskPaths {
+		if err, failed := pathErrors[disk.Path]; failed {
+			errs = append(errs, fmt.Errorf("%s: %w", disk.Path, err))
+			continue
+		}
+		devices = append(devices, pathDevices[disk.Path])
+	}
+
+	rates, names, err := getDiskIORates(devices)
+	if err != nil {
+		errs = append(errs, err)
+		for path := range pathDevices {
+			pathErrors[path] = err
+		}
+	}
+
+	maxUtil := cfg.Thresholds.MaxDiskUtil
+	maxAwait := cfg.Thresholds.MaxDiskAwait
+
+	for _, disk := range cfg.Monitoring.DiskPaths {
+		name := sanitizePath(disk.Path)
+		if err, failed := pathErrors[disk.Path]; failed {
+			samples = append(samples, Sample{
+				Name:   "diskio_" + name,
+				Err:    err,
+				NoMax:  maxUtil <= 0 && maxAwait <= 0,
+				Metric: "diskio",
+				Labels: map[string]string{"path": disk.Path},
+			})
+			continue
+		}
+
+		device := pathDevices[disk.Path]
+		deviceRates, exists := rates[device]
+		if !exists {
+			samples = append(samples, Sample{
+				Name:        "diskio_" + name,
+				Unsupported: true,
+				Metric:      "diskio",
+				Labels:      map[string]string{"path": disk.Path},
+			})
+			continue
+		}
+
+		labels := map[string]string{"path": disk.Path, "device": names[device]}
+		samples = append(samples,
+			Sample{Name: "diskio_" + name + "_read_iops", Value: deviceRates.ReadIOPS, NoMax: true, Stale: deviceRates.Stale, Metric: "diskio_read_iops", Labels: labels},
+			Sample{Name: "diskio_" + name + "_write_iops", Value: deviceRates.WriteIOPS, NoMax: true, Stale: deviceRates.Stale, Metric: "diskio_write_iops", Labels: labels},
+			Sample{Name: "diskio_" + name + "_read_bytes", Value: deviceRates.ReadBytes, NoMax: true, Stale: deviceRates.Stale, Metric: "diskio_read_bytes", Labels: labels},
+			Sample{Name: "diskio_" + name + "_write_bytes", Value: deviceRates.WriteBytes, NoMax: true, Stale: deviceRates.Stale, Metric: "diskio_write_bytes", Labels: labels},
+			Sample{Name: "diskio_" + name + "_await", Value: deviceRates.Await, Max: maxAwait, NoMax: maxAwait <= 0, Stale: deviceRates.Stale, Metric: "diskio_await", Labels: labels},
+			Sample{Name: "diskio_" + name + "_util", Value: deviceRates.Util, Max: maxUtil, NoMax: maxUtil <= 0, Stale: deviceRates.Stale, Metric: "diskio_util", Labels: labels},
+		)
+	}
+
+	return samples, errors.Join(errs...)
+}
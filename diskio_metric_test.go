@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestParseDiskStats(t *testing.T) {
+	data := `   7       0 loop0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0
+ 254       0 vda 10734 3941 1416306 7347 8690 12820 1052128 8615 0 3096 16672 12797 0 794888 708 48 1
+ 254       1 vda1 10500 3900 1400000 7200 8600 12800 1050000 8600 0 3000 16500
+`
+
+	devices, err := parseDiskStats(data)
+	if err != nil {
+		t.Fatalf("parseDiskStats() returned error: %v", err)
+	}
+
+	if len(devices) != 3 {
+		t.Fatalf("parseDiskStats() returned %d devices, want 3", len(devices))
+	}
+
+	expected := blockDeviceCounters{
+		name:           "vda",
+		reads:          10734,
+		sectorsRead:    1416306,
+		readTicks:      7347,
+		writes:         8690,
+		sectorsWritten: 1052128,
+		writeTicks:     8615,
+		ioTicks:        3096,
+	}
+	if devices["254:0"] != expected {
+		t.Errorf("parseDiskStats()[254:0] = %+v, want %+v", devices["254:0"], expected)
+	}
+
+	if devices["254:1"].name != "vda1" {
+		t.Errorf("parseDiskStats()[254:1] name = %q, want vda1", devices["254:1"].name)
+	}
+
+	if _, err := parseDiskStats("8 0 sda 1 2 3\n"); err == nil {
+		t.Error("parseDiskStats() expected error for truncated line")
+	}
+}
+
+func TestDiskIODelta(t *testing.T) {
+	previous := blockDeviceCounters{reads: 100, sectorsRead: 1000, readTicks: 50, writes: 200, sectorsWritten: 4000, writeTicks: 150, ioTicks: 1000}
+	current := blockDeviceCounters{reads: 300, sectorsRead: 5000, readTicks: 250, writes: 400, sectorsWritten: 8000, writeTicks: 550, ioTicks: 1500}
+
+	rates := diskIODelta(previous, current, 2)
+
+	expected := diskIORates{
+		ReadIOPS:   100,
+		WriteIOPS:  100,
+		ReadBytes:  4000 * 512 / 2,
+		WriteBytes: 4000 * 512 / 2,
+		Await:      600.0 / 400,
+		Util:       25,
+	}
+	if rates != expected {
+		t.Errorf("diskIODelta() = %+v, want %+v", rates, expected)
+	}
+
+	// io_ticks ahead of the wall clock is capped
+	busy := current
+	busy.ioTicks = previous.ioTicks + 3000
+	if rates := diskIODelta(previous, busy, 2); rates.Util != 100 {
+		t.Errorf("diskIODelta() util = %v, want 100", rates.Util)
+	}
+
+	if rates := diskIODelta(previous, current, 0); rates != (diskIORates{Stale: true}) {
+		t.Errorf("diskIODelta() with no elapsed time = %+v, want stale", rates)
+	}
+
+	// Device removed and added again with fresh counters
+	replaced := blockDeviceCounters{reads: 3, sectorsRead: 24, readTicks: 1, writes: 0, sectorsWritten: 0, writeTicks: 0, ioTicks: 2}
+	if rates := diskIODelta(current, replaced, 2); rates != (diskIORates{Stale: true}) {
+		t.Errorf("diskIODelta() after a counter reset = %+v, want stale", rates)
+	}
+}
+
+func TestCollectDiskIOMetrics(t *testing.T) {
+	oldConfig := config
+	config = Config{
+		startTime: time.Now(),
+	}
+	config.Thresholds.MaxDiskUtil = 90
+	config.Monitoring.DiskPaths = []DiskPath{{Path: "/"}}
+	defer func() { config = oldConfig }()
+
+	diskIOCacheMutex.Lock()
+	diskIOCache = make(map[string]diskIOSnapshot)
+	diskIOCacheMutex.Unlock()
+
+	collector := &diskIOCollector{}
+	if _, err := collector.Collect(context.Background()); err != nil {
+		t.Fatalf("diskIOCollector.Collect() returned error: %v", err)
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	samples, err := collector.Collect(context.Background())
+	if err != nil {
+		t.Fatalf("diskIOCollector.Collect() returned error: %v", err)
+	}
+
+	if len(samples) == 1 {
+		// Root is not a block device, e.g. in a container
+		if !samples[0].Unsupported {
+			t.Errorf("diskIOCollector.Collect() single sample %+v, want unsupported", samples[0])
+		}
+		return
+	}
+
+	for _, sample := range samples {
+		if sample.Value < 0 {
+			t.Errorf("%s = %v, want non-negative value", sample.Name, sample.Value)
+		}
+		if sample.Name == "diskio_root_util" && (sample.Max != 90 || sample.Value > 100) {
+			t.Errorf("diskio_root_util = %v with max %v, want value up to 100 and max 90", sample.Value, sample.Max)
+		}
+	}
+}
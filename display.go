@@ -1,14 +1,15 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"strings"
 	"time"
 )
 
-// displayMetrics shows a dstat-like terminal output of current metrics
-func displayMetrics(config Config) {
+// displayMetrics shows a dstat-like terminal output of current metrics until ctx is cancelled
+func displayMetrics(ctx context.Context, config Config) {
 	// Print header
 	printHeader()
 
@@ -16,21 +17,20 @@ func displayMetrics(config Config) {
 	defer ticker.Stop()
 
 	lineCount := 0
-	for range ticker.C {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
 		// Reprint header every 20 lines
 		if lineCount%20 == 0 && lineCount > 0 {
 			fmt.Println()
 			printHeader()
 		}
 
-		cacheMutex.RLock()
-		metrics := make(map[string]MetricStatus)
-		for k, v := range metricCache {
-			metrics[k] = v
-		}
-		cacheMutex.RUnlock()
-
-		printMetricLine(metrics)
+		printMetricLine(snapshotMetrics())
 		lineCount++
 	}
 }
@@ -101,12 +101,13 @@ func getMetricStatus(metrics map[string]MetricStatus, key string) string {
 	return "OK"
 }
 
-// getAverageDiskUsage calculates average disk usage across all monitored paths
+// getAverageDiskUsage calculates average disk space usage across all monitored paths.
+// Inode and read-only samples share the disk_ prefix but not the disk family.
 func getAverageDiskUsage(metrics map[string]MetricStatus) float64 {
 	total := 0.0
 	count := 0
-	for key, metric := range metrics {
-		if strings.HasPrefix(key, "disk_") {
+	for _, metric := range metrics {
+		if metric.metric == "disk" {
 			total += metric.Current
 			count++
 		}
@@ -117,11 +118,11 @@ func getAverageDiskUsage(metrics map[string]MetricStatus) float64 {
 	return total / float64(count)
 }
 
-// getTotalBandwidth sums bandwidth across all network interfaces
+// getTotalBandwidth sums rx and tx bytes/sec across all network interfaces
 func getTotalBandwidth(metrics map[string]MetricStatus) float64 {
 	total := 0.0
-	for key, metric := range metrics {
-		if strings.HasPrefix(key, "network_") && strings.HasSuffix(key, "_bandwidth") {
+	for _, metric := range metrics {
+		if metric.metric == "network_rx_bytes" || metric.metric == "network_tx_bytes" {
 			total += metric.Current
 		}
 	}
@@ -136,6 +137,9 @@ func printColoredValue(value float64, status string, width int) {
 	if status == "KO" {
 		// Red for KO
 		fmt.Printf("\033[31m%s\033[0m", valueStr)
+	} else if status == "UNKNOWN" {
+		// Yellow for a metric that could not be read
+		fmt.Printf("\033[33m%s\033[0m", valueStr)
 	} else {
 		// Normal color for OK
 		fmt.Print(valueStr)
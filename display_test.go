@@ -0,0 +1,17 @@
+package main
+
+import "testing"
+
+func TestGetAverageDiskUsage(t *testing.T) {
+	metrics := map[string]MetricStatus{
+		"disk_root":          {Current: 40, metric: "disk"},
+		"disk_var":           {Current: 60, metric: "disk"},
+		"disk_inodes_root":   {Current: 95, metric: "disk_inodes"},
+		"disk_readonly_root": {Current: 1, metric: "disk_readonly"},
+		"diskio_root_util":   {Current: 80, metric: "diskio_util"},
+	}
+
+	if got := getAverageDiskUsage(metrics); got != 50 {
+		t.Errorf("getAverageDiskUsage() = %v, want 50 (space usage only)", got)
+	}
+}
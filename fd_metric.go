@@ -0,0 +1,222 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxCommLength is the length at which the kernel truncates /proc/<pid>/comm
+const maxCommLength = 15
+
+// fileNr holds the content of /proc/sys/fs/file-nr
+type fileNr struct {
+	Allocated float64 // File handles allocated
+	Free      float64 // Allocated but unused file handles, always 0 since Linux 2.6
+	Max       float64 // fs.file-max
+}
+
+// parseFileNr parses the content of /proc/sys/fs/file-nr.
+// Format: 9344	0	9223372036854775807
+func parseFileNr(data string) (fileNr, error) {
+	fields := strings.Fields(data)
+	if len(fields) != 3 {
+		return fileNr{}, fmt.Errorf("expected 3 fields, got %d", len(fields))
+	}
+
+	var values [3]float64
+	for i, field := range fields {
+		value, err := strconv.ParseUint(field, 10, 64)
+		if err != nil {
+			return fileNr{}, fmt.Errorf("invalid value: %w", err)
+		}
+		values[i] = float64(value)
+	}
+
+	return fileNr{Allocated: values[0], Free: values[1], Max: values[2]}, nil
+}
+
+// readProcUint reads a file holding a single unsigned integer, such as a sysctl
+func readProcUint(path string) (float64, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	value, err := strconv.ParseUint(strings.TrimSpace(string(data)), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", path, err)
+	}
+	return float64(value), nil
+}
+
+// parseNoFileLimit returns the soft "Max open files" limit from /proc/<pid>/limits,
+// or 0 when unlimited.
+// Format: Max open files            1024                 524288               files
+func parseNoFileLimit(data string) (float64, error) {
+	for _, line := range strings.Split(data, "\n") {
+		rest, found := strings.CutPrefix(line, "Max open files")
+		if !found {
+			continue
+		}
+
+		fields := strings.Fields(rest)
+		if len(fields) == 0 {
+			return 0, fmt.Errorf("missing open files limit")
+		}
+		if fields[0] == "unlimited" {
+			return 0, nil
+		}
+		soft, err := strconv.ParseUint(fields[0], 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid open files limit: %w", err)
+		}
+		return float64(soft), nil
+	}
+
+	return 0, fmt.Errorf("open files limit not found")
+}
+
+// percentOf returns value as a percentage of limit, or 0 without a limit
+func percentOf(value, limit float64) float64 {
+	if limit <= 0 {
+		return 0
+	}
+	return value / limit * 100.0
+}
+
+// findProcessesByName returns the PIDs whose /proc/<pid>/comm matches name.
+// Names longer than the kernel comm length are compared on their truncated prefix.
+func findProcessesByName(name string) ([]int, error) {
+	if len(name) > maxCommLength {
+		name = name[:maxCommLength]
+	}
+
+	return scanProcesses(func(pid int) bool {
+		comm, err := os.ReadFile(procPath(strconv.Itoa(pid), "comm"))
+		return err == nil && strings.TrimSpace(string(comm)) == name
+	})
+}
+
+// getProcessFDUsage returns the open file count of a process and its soft RLIMIT_NOFILE
+func getProcessFDUsage(pid int) (float64, float64, error) {
+	entries, err := os.ReadDir(procPath(strconv.Itoa(pid), "fd"))
+	if err != nil {
+		return 0, 0, err
+	}
+
+	data, err := os.ReadFile(procPath(strconv.Itoa(pid), "limits"))
+	if err != nil {
+		return 0, 0, err
+	}
+	limit, err := parseNoFileLimit(string(data))
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return float64(len(entries)), limit, nil
+}
+
+// fdCollector reports file descriptor and process table usage
+type fdCollector struct{}
+
+// Name identifies the collector in logs
+func (c *fdCollector) Name() string {
+	return "fd"
+}
+
+// Interval is the delay between two file descriptor collections
+func (c *fdCollector) Interval() time.Duration {
+	return collectorInterval(c.Name(), 5*time.Second)
+}
+
+// Collect returns system-wide file handle, PID and thread usage percentages,
+// and for every process in config.Monitoring.Processes the open files of its
+// busiest instance as a percentage of that instance's RLIMIT_NOFILE.
+func (c *fdCollector) Collect(ctx context.Context) ([]Sample, error) {
+	cfg := currentConfig()
+	var samples []Sample
+	var errs []error
+
+	// System-wide file handles
+	var files fileNr
+	data, filesErr := os.ReadFile(procPath("sys", "fs", "file-nr"))
+	if filesErr == nil {
+		files, filesErr = parseFileNr(string(data))
+	}
+	if filesErr != nil {
+		errs = append(errs, fmt.Errorf("file-nr: %w", filesErr))
+	}
+
+	// Every thread uses a PID, so tasks are compared to both limits
+	load, loadErr := getLoadAverage()
+	if loadErr != nil {
+		errs = append(errs, fmt.Errorf("loadavg: %w", loadErr))
+	}
+	pidMax, pidErr := readProcUint(procPath("sys", "kernel", "pid_max"))
+	if pidErr != nil {
+		errs = append(errs, pidErr)
+	}
+	threadsMax, threadsErr := readProcUint(procPath("sys", "kernel", "threads-max"))
+	if threadsErr != nil {
+		errs = append(errs, threadsErr)
+	}
+
+	fileSamples := []Sample{
+		thresholdSample("fd_usage", percentOf(files.Allocated-files.Free, files.Max), cfg.Thresholds.MaxFDUsage),
+		{Name: "fd_open", Value: files.Allocated - files.Free, NoMax: true},
+	}
+	if filesErr != nil {
+		failSamples(fileSamples, filesErr)
+	}
+	pidUsage := thresholdSample("pid_usage", percentOf(load.Total, pidMax), cfg.Thresholds.MaxPIDUsage)
+	pidUsage.Err = errors.Join(loadErr, pidErr)
+	threadUsage := thresholdSample("thread_usage", percentOf(load.Total, threadsMax), cfg.Thresholds.MaxThreadUsage)
+	threadUsage.Err = errors.Join(loadErr, threadsErr)
+	samples = append(fileSamples, pidUsage, threadUsage)
+
+	for _, process := range cfg.Monitoring.Processes {
+		maxUsage := cfg.Thresholds.MaxProcessFDUsage
+		if process.MaxFDUsage > 0 {
+			maxUsage = process.MaxFDUsage
+		}
+		labels := map[string]string{"process": process.Name}
+
+		pids, err := findProcesses(process)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", process.Name, err))
+			samples = append(samples, Sample{Name: "process_" + process.Name + "_fd_usage", Max: maxUsage, NoMax: maxUsage <= 0, Err: err, Metric: "process_fd_usage", Labels: labels})
+			continue
+		}
+
+		// Report the instance closest to its own limit
+		found := false
+		openFiles, usage := 0.0, 0.0
+		for _, pid := range pids {
+			count, limit, err := getProcessFDUsage(pid)
+			if err != nil {
+				if !errors.Is(err, os.ErrNotExist) {
+					errs = append(errs, fmt.Errorf("%s[%d]: %w", process.Name, pid, err))
+				}
+				continue
+			}
+			found = true
+			if percent := percentOf(count, limit); percent >= usage {
+				openFiles, usage = count, percent
+			}
+		}
+		if !found {
+			continue
+		}
+
+		samples = append(samples,
+			Sample{Name: "process_" + process.Name + "_fd_usage", Value: usage, Max: maxUsage, NoMax: maxUsage <= 0, Metric: "process_fd_usage", Labels: labels},
+			Sample{Name: "process_" + process.Name + "_fds", Value: openFiles, NoMax: true, Metric: "process_fds", Labels: labels},
+		)
+	}
+
+	return samples, errors.Join(errs...)
+}
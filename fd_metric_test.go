@@ -0,0 +1,188 @@
+package main
+
+import (
+	"context"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestParseFileNr(t *testing.T) {
+	files, err := parseFileNr("9344\t0\t9223372036854775807\n")
+	if err != nil {
+		t.Fatalf("parseFileNr() returned error: %v", err)
+	}
+	if files.Allocated != 9344 || files.Free != 0 || files.Max != 9223372036854775807 {
+		t.Errorf("parseFileNr() = %+v", files)
+	}
+
+	if _, err := parseFileNr("9344 0\n"); err == nil {
+		t.Error("parseFileNr() expected error for missing field")
+	}
+}
+
+func TestParseNoFileLimit(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		want    float64
+		wantErr bool
+	}{
+		{
+			name: "soft limit",
+			data: "Limit                     Soft Limit           Hard Limit           Units     \n" +
+				"Max processes             63460                63460                processes \n" +
+				"Max open files            1024                 524288               files     \n",
+			want: 1024,
+		},
+		{
+			name: "unlimited",
+			data: "Max open files            unlimited            unlimited            files     \n",
+			want: 0,
+		},
+		{
+			name:    "missing line",
+			data:    "Max processes             63460                63460                processes \n",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseNoFileLimit(tt.data)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseNoFileLimit() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("parseNoFileLimit() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFindProcessesByName(t *testing.T) {
+	comm, err := os.ReadFile("/proc/self/comm")
+	if err != nil {
+		t.Skipf("no procfs: %v", err)
+	}
+
+	// The test binary name may be longer than the kernel comm length
+	pids, err := findProcessesByName(filepath.Base(os.Args[0]))
+	if err != nil {
+		t.Fatalf("findProcessesByName() returned error: %v", err)
+	}
+
+	found := false
+	for _, pid := range pids {
+		if pid == os.Getpid() {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("findProcessesByName(%q) = %v, want to include own PID %d (comm %q)", filepath.Base(os.Args[0]), pids, os.Getpid(), comm)
+	}
+}
+
+func TestCollectFDMetrics(t *testing.T) {
+	oldConfig := config
+	config = Config{
+		startTime: time.Now(),
+	}
+	config.Thresholds.MaxFDUsage = 90
+	config.Thresholds.MaxProcessFDUsage = 80
+	config.Monitoring.Processes = []ProcessMonitor{{Name: filepath.Base(os.Args[0]), MaxFDUsage: 95}, {Name: "no-such-process"}}
+	defer func() { config = oldConfig }()
+
+	samples, err := (&fdCollector{}).Collect(context.Background())
+	if err != nil {
+		t.Fatalf("fdCollector.Collect() returned error: %v", err)
+	}
+
+	values := make(map[string]Sample)
+	for _, sample := range samples {
+		values[sample.Name] = sample
+	}
+
+	for _, name := range []string{"fd_usage", "fd_open", "pid_usage", "thread_usage"} {
+		sample, exists := values[name]
+		if !exists {
+			t.Errorf("%s sample missing", name)
+			continue
+		}
+		if sample.Value < 0 || sample.Value > 100 && name != "fd_open" {
+			t.Errorf("%s = %v, want a percentage", name, sample.Value)
+		}
+	}
+	if values["fd_usage"].Max != 90 {
+		t.Errorf("fd_usage max = %v, want 90", values["fd_usage"].Max)
+	}
+
+	own := values["process_"+filepath.Base(os.Args[0])+"_fd_usage"]
+	if own.Max != 95 || own.Value <= 0 {
+		t.Errorf("own process fd usage = %+v, want a positive value with max 95", own)
+	}
+	if _, exists := values["process_no-such-process_fd_usage"]; exists {
+		t.Error("missing process should not be reported")
+	}
+}
+
+func TestCollectFDMetricsFixtures(t *testing.T) {
+	oldConfig := config
+	config = Config{
+		startTime: time.Now(),
+	}
+	config.Thresholds.MaxFDUsage = 90
+	config.Monitoring.Processes = []ProcessMonitor{{Name: "nginx", MaxFDUsage: 80}}
+	defer func() { config = oldConfig }()
+
+	tests := []struct {
+		fixture string
+		want    map[string]float64
+	}{
+		{
+			fixture: "idle",
+			want: map[string]float64{
+				"fd_usage":               1.024,
+				"fd_open":                1024,
+				"thread_usage":           300.0 / 48000 * 100,
+				"process_nginx_fds":      3,
+				"process_nginx_fd_usage": 3.0 / 1024 * 100,
+			},
+		},
+		{
+			fixture: "busy",
+			want: map[string]float64{
+				"fd_usage":               95,
+				"fd_open":                95000,
+				"thread_usage":           4000.0 / 48000 * 100,
+				"process_nginx_fds":      10,
+				"process_nginx_fd_usage": 100,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.fixture, func(t *testing.T) {
+			useFixture(tt.fixture)
+			samples, err := (&fdCollector{}).Collect(context.Background())
+			if err != nil {
+				t.Fatalf("fdCollector.Collect() returned error: %v", err)
+			}
+
+			values := make(map[string]Sample)
+			for _, sample := range samples {
+				values[sample.Name] = sample
+			}
+			for name, want := range tt.want {
+				if got := values[name].Value; math.Abs(got-want) > 1e-9 {
+					t.Errorf("%s = %v, want %v", name, got, want)
+				}
+			}
+			if values["process_nginx_fd_usage"].Max != 80 {
+				t.Errorf("process_nginx_fd_usage max = %v, want 80", values["process_nginx_fd_usage"].Max)
+			}
+		})
+	}
+}
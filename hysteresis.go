@@ -0,0 +1,99 @@
+package main
+
+// hysteresisRule returns the hysteresis settings for a metric.
+// Settings for the cache key take precedence over the metric family,
+// and unset fields fall back to the global settings.
+func hysteresisRule(name, metric string) HysteresisRule {
+	cfg := currentConfig()
+	rule := cfg.Hysteresis.HysteresisRule
+
+	for _, key := range []string{metric, name} {
+		override, exists := cfg.Hysteresis.Metrics[key]
+		if !exists {
+			continue
+		}
+		if override.KOAfter > 0 {
+			rule.KOAfter = override.KOAfter
+		}
+		if override.OKAfter > 0 {
+			rule.OKAfter = override.OKAfter
+		}
+		if override.Recovery > 0 {
+			rule.Recovery = override.Recovery
+		}
+	}
+
+	// Defaults flip status on every sample, as without hysteresis
+	if rule.KOAfter < 1 {
+		rule.KOAfter = 1
+	}
+	if rule.OKAfter < 1 {
+		rule.OKAfter = 1
+	}
+	if rule.Recovery <= 0 {
+		rule.Recovery = 100.0
+	}
+
+	return rule
+}
+
+// lastKnownStatus returns the status of a metric, or the last OK or KO status it
+// had before turning UNKNOWN or STALE
+func lastKnownStatus(metric MetricStatus) string {
+	if metric.Status == "UNKNOWN" || metric.Status == "STALE" {
+		return metric.lastKnown
+	}
+	return metric.Status
+}
+
+// debounceStatus applies hysteresis to a freshly evaluated metric.
+// An OK metric goes KO after rule.KOAfter consecutive samples out of bounds, and a KO
+// metric returns OK after rule.OKAfter consecutive samples at or below the recovery
+// threshold and at or above the minimum. The streak counts samples pending a transition.
+func debounceStatus(metric, previous MetricStatus, exists bool, rule HysteresisRule) MetricStatus {
+	// A new metric starts from OK with no pending transition
+	if !exists {
+		previous = MetricStatus{Status: "OK"}
+	}
+
+	// A KO metric that went UNKNOWN or STALE has not recovered yet
+	if previous.Status == "UNKNOWN" || previous.Status == "STALE" {
+		status := "OK"
+		if previous.lastKnown == "KO" {
+			status = "KO"
+		}
+		previous = MetricStatus{Status: status}
+	}
+
+	if previous.Status == "KO" {
+		recoveryMax := metric.Max * rule.Recovery / 100.0
+		if (!metric.noMax && metric.Current > recoveryMax) || metric.Current < metric.Min {
+			metric.Status = "KO"
+			metric.Streak = 0
+			return metric
+		}
+
+		metric.Streak = previous.Streak + 1
+		if metric.Streak >= rule.OKAfter {
+			metric.Status = "OK"
+			metric.Streak = 0
+		} else {
+			metric.Status = "KO"
+		}
+		return metric
+	}
+
+	if metric.Status != "KO" {
+		metric.Streak = 0
+		return metric
+	}
+
+	metric.Streak = previous.Streak + 1
+	if metric.Streak >= rule.KOAfter {
+		metric.Status = "KO"
+		metric.Streak = 0
+	} else {
+		metric.Status = "OK"
+	}
+	return metric
+}
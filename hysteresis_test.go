@@ -0,0 +1,165 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestDebounceStatus(t *testing.T) {
+	rule := HysteresisRule{KOAfter: 3, OKAfter: 2, Recovery: 90.0}
+
+	// Max is 100, recovery threshold is 90
+	steps := []struct {
+		current    float64
+		wantStatus string
+		wantStreak int
+	}{
+		{current: 50, wantStatus: "OK", wantStreak: 0},
+		{current: 120, wantStatus: "OK", wantStreak: 1},
+		{current: 120, wantStatus: "OK", wantStreak: 2},
+		{current: 80, wantStatus: "OK", wantStreak: 0}, // Spike ended before ko_after
+		{current: 120, wantStatus: "OK", wantStreak: 1},
+		{current: 120, wantStatus: "OK", wantStreak: 2},
+		{current: 120, wantStatus: "KO", wantStreak: 0},
+		{current: 95, wantStatus: "KO", wantStreak: 0}, // Below max but above recovery
+		{current: 85, wantStatus: "KO", wantStreak: 1},
+		{current: 85, wantStatus: "OK", wantStreak: 0},
+	}
+
+	var previous MetricStatus
+	exists := false
+	for i, step := range steps {
+		metric := evaluateSample(Sample{Name: "test", Value: step.current, Max: 100}, 1.0)
+		metric = debounceStatus(metric, previous, exists, rule)
+
+		if metric.Status != step.wantStatus || metric.Streak != step.wantStreak {
+			t.Errorf("step %d (current=%v): status = %v streak = %v, want %v streak %v",
+				i, step.current, metric.Status, metric.Streak, step.wantStatus, step.wantStreak)
+		}
+
+		previous = metric
+		exists = true
+	}
+}
+
+func TestDebounceStatusMinimum(t *testing.T) {
+	rule := HysteresisRule{KOAfter: 2, OKAfter: 2, Recovery: 90.0}
+
+	// Instance count with a minimum of 2 and no maximum
+	steps := []struct {
+		current    float64
+		wantStatus string
+	}{
+		{current: 2, wantStatus: "OK"},
+		{current: 1, wantStatus: "OK"},
+		{current: 1, wantStatus: "KO"},
+		{current: 3, wantStatus: "KO"},
+		{current: 3, wantStatus: "OK"},
+	}
+
+	var previous MetricStatus
+	exists := false
+	for i, step := range steps {
+		metric := evaluateSample(Sample{Name: "test", Value: step.current, NoMax: true, Min: 2}, 1.0)
+		metric = debounceStatus(metric, previous, exists, rule)
+
+		if metric.Status != step.wantStatus {
+			t.Errorf("step %d (current=%v): status = %v, want %v", i, step.current, metric.Status, step.wantStatus)
+		}
+
+		previous = metric
+		exists = true
+	}
+}
+
+func TestHysteresisRule(t *testing.T) {
+	oldConfig := config
+	defer func() { config = oldConfig }()
+
+	config = Config{
+		startTime: time.Now(),
+	}
+	config.Hysteresis.KOAfter = 2
+	config.Hysteresis.OKAfter = 4
+	config.Hysteresis.Recovery = 80.0
+	config.Hysteresis.Metrics = map[string]HysteresisRule{
+		"disk":      {KOAfter: 5},
+		"disk_root": {OKAfter: 1},
+		"cpu_usage": {Recovery: 70.0},
+	}
+
+	tests := []struct {
+		name   string
+		key    string
+		metric string
+		want   HysteresisRule
+	}{
+		{
+			name:   "global settings",
+			key:    "memory",
+			metric: "memory",
+			want:   HysteresisRule{KOAfter: 2, OKAfter: 4, Recovery: 80.0},
+		},
+		{
+			name:   "family override",
+			key:    "disk_var",
+			metric: "disk",
+			want:   HysteresisRule{KOAfter: 5, OKAfter: 4, Recovery: 80.0},
+		},
+		{
+			name:   "key override on top of family",
+			key:    "disk_root",
+			metric: "disk",
+			want:   HysteresisRule{KOAfter: 5, OKAfter: 1, Recovery: 80.0},
+		},
+		{
+			name:   "recovery override",
+			key:    "cpu_usage",
+			metric: "cpu_usage",
+			want:   HysteresisRule{KOAfter: 2, OKAfter: 4, Recovery: 70.0},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := hysteresisRule(tt.key, tt.metric)
+			if got != tt.want {
+				t.Errorf("hysteresisRule(%q, %q) = %+v, want %+v", tt.key, tt.metric, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDebounceStatusAfterUnknown(t *testing.T) {
+	oldConfig := config
+	defer func() { config = oldConfig }()
+
+	config = Config{startTime: time.Now()}
+	config.Hysteresis.OKAfter = 3
+
+	cacheMutex.Lock()
+	metricCache = make(map[string]MetricStatus)
+	cacheMutex.Unlock()
+
+	readErr := errors.New("read error")
+	steps := []struct {
+		sample     Sample
+		wantStatus string
+	}{
+		{sample: Sample{Value: 120, Max: 100}, wantStatus: "KO"},
+		{sample: Sample{Max: 100, Err: readErr}, wantStatus: "UNKNOWN"},
+		{sample: Sample{Max: 100, Stale: true}, wantStatus: "STALE"},
+		{sample: Sample{Value: 50, Max: 100}, wantStatus: "KO"}, // Still recovering after the unknown readings
+		{sample: Sample{Value: 50, Max: 100}, wantStatus: "KO"},
+		{sample: Sample{Value: 50, Max: 100}, wantStatus: "OK"},
+	}
+
+	for i, step := range steps {
+		step.sample.Name = "test"
+		publishSamples([]Sample{step.sample})
+		if metric := snapshotMetrics()["test"]; metric.Status != step.wantStatus {
+			t.Errorf("step %d: status = %v, want %v", i, metric.Status, step.wantStatus)
+		}
+	}
+}
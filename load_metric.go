@@ -0,0 +1,145 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// loadAverage holds the content of /proc/loadavg
+type loadAverage struct {
+	Load1   float64 // Run queue average over 1 minute
+	Load5   float64 // Run queue average over 5 minutes
+	Load15  float64 // Run queue average over 15 minutes
+	Running float64 // Currently runnable tasks
+	Total   float64 // Total tasks (threads) on the system
+}
+
+// parseLoadAvg parses the content of /proc/loadavg.
+// Format: 0.52 0.58 0.59 2/345 12345
+func parseLoadAvg(data string) (loadAverage, error) {
+	fields := strings.Fields(data)
+	if len(fields) < 4 {
+		return loadAverage{}, fmt.Errorf("expected at least 4 fields, got %d", len(fields))
+	}
+
+	var load loadAverage
+	var err error
+	if load.Load1, err = strconv.ParseFloat(fields[0], 64); err != nil {
+		return loadAverage{}, fmt.Errorf("invalid load1: %w", err)
+	}
+	if load.Load5, err = strconv.ParseFloat(fields[1], 64); err != nil {
+		return loadAverage{}, fmt.Errorf("invalid load5: %w", err)
+	}
+	if load.Load15, err = strconv.ParseFloat(fields[2], 64); err != nil {
+		return loadAverage{}, fmt.Errorf("invalid load15: %w", err)
+	}
+
+	running, total, found := strings.Cut(fields[3], "/")
+	if !found {
+		return loadAverage{}, fmt.Errorf("invalid task counts %q", fields[3])
+	}
+	if load.Running, err = strconv.ParseFloat(running, 64); err != nil {
+		return loadAverage{}, fmt.Errorf("invalid running tasks: %w", err)
+	}
+	if load.Total, err = strconv.ParseFloat(total, 64); err != nil {
+		return loadAverage{}, fmt.Errorf("invalid total tasks: %w", err)
+	}
+
+	return load, nil
+}
+
+// getLoadAverage reads /proc/loadavg
+func getLoadAverage() (loadAverage, error) {
+	data, err := os.ReadFile(procPath("loadavg"))
+	if err != nil {
+		return loadAverage{}, err
+	}
+	return parseLoadAvg(string(data))
+}
+
+// parseCPUList counts the CPUs in a kernel CPU list such as "0-3,5,7-8"
+func parseCPUList(list string) (int, error) {
+	count := 0
+	for _, part := range strings.Split(strings.TrimSpace(list), ",") {
+		if part == "" {
+			continue
+		}
+
+		first, last, isRange := strings.Cut(part, "-")
+		start, err := strconv.Atoi(first)
+		if err != nil {
+			return 0, fmt.Errorf("invalid CPU list %q", list)
+		}
+		end := start
+		if isRange {
+			if end, err = strconv.Atoi(last); err != nil || end < start {
+				return 0, fmt.Errorf("invalid CPU list %q", list)
+			}
+		}
+		count += end - start + 1
+	}
+
+	if count == 0 {
+		return 0, fmt.Errorf("empty CPU list")
+	}
+	return count, nil
+}
+
+// getOnlineCPUs returns the number of online CPUs,
+// falling back to the CPUs usable by the probe when sysfs is unavailable
+func getOnlineCPUs() int {
+	data, err := os.ReadFile(sysPath("devices", "system", "cpu", "online"))
+	if err == nil {
+		if count, err := parseCPUList(string(data)); err == nil {
+			return count
+		}
+	}
+	return runtime.NumCPU()
+}
+
+// loadCollector reports load averages and run queue length
+type loadCollector struct{}
+
+// Name identifies the collector in logs
+func (c *loadCollector) Name() string {
+	return "load"
+}
+
+// Interval is the delay between two load collections
+func (c *loadCollector) Interval() time.Duration {
+	return collectorInterval(c.Name(), 2*time.Second)
+}
+
+// Collect returns load1/5/15 and task count samples. When
+// config.Thresholds.LoadPerCore is set, load and running task thresholds
+// are ratios multiplied by the online CPU count.
+func (c *loadCollector) Collect(ctx context.Context) ([]Sample, error) {
+	cfg := currentConfig()
+
+	load, err := getLoadAverage()
+
+	scale := 1.0
+	cpus := getOnlineCPUs()
+	if cfg.Thresholds.LoadPerCore {
+		scale = float64(cpus)
+	}
+
+	samples := []Sample{
+		thresholdSample("load1", load.Load1, cfg.Thresholds.MaxLoad1*scale),
+		thresholdSample("load5", load.Load5, cfg.Thresholds.MaxLoad5*scale),
+		thresholdSample("load15", load.Load15, cfg.Thresholds.MaxLoad15*scale),
+		thresholdSample("procs_running", load.Running, cfg.Thresholds.MaxProcsRunning*scale),
+		{Name: "procs_total", Value: load.Total, NoMax: true},
+	}
+	if err != nil {
+		failSamples(samples, err)
+	}
+	samples = append(samples, Sample{Name: "cpu_online", Value: float64(cpus), NoMax: true})
+
+	return samples, err
+}
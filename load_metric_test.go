@@ -0,0 +1,141 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestParseLoadAvg(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		want    loadAverage
+		wantErr bool
+	}{
+		{
+			name: "standard",
+			data: "0.52 0.58 0.59 2/345 12345\n",
+			want: loadAverage{Load1: 0.52, Load5: 0.58, Load15: 0.59, Running: 2, Total: 345},
+		},
+		{
+			name:    "missing task counts",
+			data:    "0.52 0.58 0.59\n",
+			wantErr: true,
+		},
+		{
+			name:    "invalid task counts",
+			data:    "0.52 0.58 0.59 2-345 12345\n",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseLoadAvg(tt.data)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseLoadAvg() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("parseLoadAvg() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseCPUList(t *testing.T) {
+	tests := []struct {
+		list    string
+		want    int
+		wantErr bool
+	}{
+		{list: "0", want: 1},
+		{list: "0-3\n", want: 4},
+		{list: "0-3,5,7-8", want: 7},
+		{list: "", wantErr: true},
+		{list: "3-1", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.list, func(t *testing.T) {
+			got, err := parseCPUList(tt.list)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseCPUList(%q) error = %v, wantErr %v", tt.list, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("parseCPUList(%q) = %v, want %v", tt.list, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetLoadAverageFixtures(t *testing.T) {
+	oldConfig := config
+	defer func() { config = oldConfig }()
+
+	tests := []struct {
+		fixture  string
+		want     loadAverage
+		wantCPUs int
+	}{
+		{fixture: "idle", want: loadAverage{Load1: 0.5, Load5: 0.4, Load15: 0.3, Running: 2, Total: 300}, wantCPUs: 2},
+		{fixture: "busy", want: loadAverage{Load1: 12, Load5: 8, Load15: 4, Running: 10, Total: 4000}, wantCPUs: 2},
+		{fixture: "legacy", want: loadAverage{Load1: 0, Load5: 0.01, Load15: 0.05, Running: 1, Total: 120}, wantCPUs: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.fixture, func(t *testing.T) {
+			useFixture(tt.fixture)
+			load, err := getLoadAverage()
+			if err != nil {
+				t.Fatalf("getLoadAverage() returned error: %v", err)
+			}
+			if load != tt.want {
+				t.Errorf("getLoadAverage() = %+v, want %+v", load, tt.want)
+			}
+			if cpus := getOnlineCPUs(); cpus != tt.wantCPUs {
+				t.Errorf("getOnlineCPUs() = %v, want %v", cpus, tt.wantCPUs)
+			}
+		})
+	}
+}
+
+func TestLoadCollectorPerCoreThresholds(t *testing.T) {
+	oldConfig := config
+	config = Config{
+		startTime: time.Now(),
+	}
+	config.Thresholds.MaxLoad5 = 2.0
+	config.Thresholds.LoadPerCore = true
+	defer func() { config = oldConfig }()
+
+	samples, err := (&loadCollector{}).Collect(context.Background())
+	if err != nil {
+		t.Fatalf("loadCollector.Collect() returned error: %v", err)
+	}
+
+	cpus := float64(getOnlineCPUs())
+	byName := make(map[string]Sample)
+	for _, sample := range samples {
+		byName[sample.Name] = sample
+	}
+
+	if load5 := byName["load5"]; load5.NoMax || load5.Max != 2.0*cpus {
+		t.Errorf("load5 max = %v (NoMax %v), want %v", load5.Max, load5.NoMax, 2.0*cpus)
+	}
+	if load1 := byName["load1"]; !load1.NoMax {
+		t.Errorf("load1 has a threshold, want none when max_load1 is 0")
+	}
+	if total := byName["procs_total"]; total.Value < 1 {
+		t.Errorf("procs_total = %v, want at least 1", total.Value)
+	}
+
+	// Absolute thresholds are used as is
+	config.Thresholds.LoadPerCore = false
+	samples, _ = (&loadCollector{}).Collect(context.Background())
+	for _, sample := range samples {
+		if sample.Name == "load5" && sample.Max != 2.0 {
+			t.Errorf("absolute load5 max = %v, want 2", sample.Max)
+		}
+	}
+}
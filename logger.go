@@ -42,6 +42,19 @@ func setupLogging(config Config) (*os.File, error) {
 	return logFile, nil
 }
 
+// closeLogging flushes and closes the log file; later messages go to stderr only
+func closeLogging(logFile *os.File) error {
+	log.SetOutput(os.Stderr)
+	if logFile == nil {
+		return nil
+	}
+	if err := logFile.Sync(); err != nil {
+		logFile.Close()
+		return err
+	}
+	return logFile.Close()
+}
+
 // logDebug logs a message only if debug mode is enabled
 func logDebug(config Config, format string, args ...interface{}) {
 	if config.Logging.Debug {
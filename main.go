@@ -1,11 +1,16 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"log"
+	"net"
 	"net/http"
 	"os"
+	"os/signal"
 	"sync"
+	"syscall"
 	"time"
 )
 
@@ -13,48 +18,86 @@ import (
 type MetricStatus struct {
 	Current float64 `json:"current"`
 	Max     float64 `json:"max"`
+	Min     float64 `json:"min,omitempty"` // Minimum value, 0 when unbounded
 	Status  string  `json:"status"`
+	Streak  int     `json:"streak"`          // Consecutive samples pending a status change
+	Error   string  `json:"error,omitempty"` // Why the metric is UNKNOWN
+
+	// Exposition fields (not in JSON)
+	metric    string            // Metric family, e.g. "disk" for "disk_var_log"
+	labels    map[string]string // Labels identifying the metric within its family
+	noMax     bool              // Only the minimum is checked
+	unknownKO bool              // UNKNOWN counts as KO under the ko unknown policy
+	lastKnown string            // Last OK or KO status of an UNKNOWN or STALE metric
 }
 
 // HealthResponse represents the JSON response structure
 type HealthResponse struct {
 	Status    string                  `json:"status"`
 	Timestamp time.Time               `json:"timestamp"`
+	Admin     *AdminState             `json:"admin,omitempty"`
 	Metrics   map[string]MetricStatus `json:"metrics"`
 }
 
 var (
 	config      Config
+	configMutex sync.RWMutex
 	metricCache = make(map[string]MetricStatus)
 	cacheMutex  sync.RWMutex
 )
 
-// healthHandler handles the /health endpoint
-func healthHandler(w http.ResponseWriter, r *http.Request) {
+// snapshotMetrics returns a copy of metricCache
+func snapshotMetrics() map[string]MetricStatus {
 	cacheMutex.RLock()
-	metrics := make(map[string]MetricStatus)
+	defer cacheMutex.RUnlock()
+
+	metrics := make(map[string]MetricStatus, len(metricCache))
 	for k, v := range metricCache {
 		metrics[k] = v
 	}
-	cacheMutex.RUnlock()
+	return metrics
+}
+
+// metricFailing reports whether a metric makes the probe KO: a KO metric, or an
+// UNKNOWN metric under the ko unknown policy. Informational metrics, with neither
+// a max nor a minimum, never fail the probe.
+func metricFailing(metric MetricStatus) bool {
+	if metric.Status == "UNKNOWN" {
+		return metric.unknownKO && !(metric.noMax && metric.Min == 0)
+	}
+	return metric.Status == "KO"
+}
 
-	// Determine overall status
-	overallStatus := "OK"
+// overallStatus returns KO if any metric is failing, OK otherwise
+func overallStatus(metrics map[string]MetricStatus) string {
 	for _, metric := range metrics {
-		if metric.Status == "KO" {
-			overallStatus = "KO"
-			break
+		if metricFailing(metric) {
+			return "KO"
 		}
 	}
+	return "OK"
+}
+
+// healthHandler handles the /health endpoint
+func healthHandler(w http.ResponseWriter, r *http.Request) {
+	metrics := snapshotMetrics()
+	status := overallStatus(metrics)
+
+	// Drain and maintenance modes force KO regardless of metrics
+	admin := currentAdminState()
+	if admin != nil {
+		status = "KO"
+	}
 
 	response := HealthResponse{
-		Status:    overallStatus,
+		Status:    status,
 		Timestamp: time.Now(),
+		Admin:     admin,
 		Metrics:   metrics,
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	if overallStatus == "KO" {
+	if status == "KO" {
 		w.WriteHeader(http.StatusServiceUnavailable)
 	} else {
 		w.WriteHeader(http.StatusOK)
@@ -93,10 +136,20 @@ func main() {
 	if err != nil {
 		log.Printf("Warning: Failed to setup logging: %v", err)
 	}
-	if logFile != nil {
-		defer logFile.Close()
+
+	// Reported once here, reloads log their own outcome
+	if _, err := os.Stat(flags.ConfigFile); err == nil {
+		logInfo("Loaded configuration from: %s", flags.ConfigFile)
+	} else {
+		logInfo("Config file not found, using defaults: %s", flags.ConfigFile)
 	}
 
+	// Catch SIGTERM and SIGINT from the start so the probe always shuts down cleanly
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGTERM, syscall.SIGINT)
+	ctx, stopCollectors := context.WithCancel(context.Background())
+	defer stopCollectors()
+
 	logInfo("Starting probe with config: warmup=%v, duration=%v",
 		config.Warmup.Enabled, config.Warmup.Duration)
 	logInfo("CPU Thresholds: Usage=%.1f%%, IOWait=%.1f%%, IRQ=%.1f%%, SoftIRQ=%.1f%%",
@@ -105,27 +158,87 @@ func main() {
 		config.Thresholds.MaxMemory, config.Thresholds.MaxDisk, config.Thresholds.MaxConnections)
 	logInfo("Monitoring disk paths: %v", config.Monitoring.DiskPaths)
 	logInfo("Monitoring network interfaces: %v", config.Monitoring.NetworkInterfaces)
+	logInfo("Monitoring ports: %v", config.Monitoring.Ports)
+	logInfo("Active checks: %v", config.Checks)
+	logInfo("Reading procfs from %s and sysfs from %s", config.Paths.Proc, config.Paths.Sys)
 	logInfo("Logging to: %s", config.Logging.File)
 	logDebug(config, "Debug logging enabled")
 
-	// Start metric collection goroutines
-	go collectCPUMetric()
-	go collectMemoryMetric()
-	go collectDiskMetric()
-	go collectNetworkMetric()
+	// Restore drain or maintenance mode from a previous run
+	if err := loadAdminState(config.Admin.StateFile); err != nil {
+		logWarning("Failed to restore admin state: %v", err)
+	}
+	go watchAdminSignals(ctx)
+
+	// Register metric collectors and start their goroutines
+	registry := newRegistry()
+	registry.Register(&cpuCollector{})
+	registry.Register(&memoryCollector{})
+	registry.Register(&diskCollector{})
+	registry.Register(&diskIOCollector{})
+	registry.Register(&networkCollector{})
+	registry.Register(&psiCollector{})
+	registry.Register(&loadCollector{})
+	registry.Register(&cgroupCollector{})
+	registry.Register(&fdCollector{})
+	registry.Register(&processCollector{})
+	registerChecks(registry, config.Checks)
+	registry.Start(ctx)
+
+	// Reload configuration on SIGHUP or file change
+	go watchConfig(ctx, flags, registry)
 
 	// Start display if enabled
 	if config.Display.Enabled {
 		logInfo("Starting metrics display (interval: %v)", config.Display.Interval)
-		go displayMetrics(config)
+		go displayMetrics(ctx, config)
+	}
+
+	// Start HAProxy agent-check listener if enabled
+	var agentListener net.Listener
+	if config.Agent.Enabled {
+		agentListener, err = net.Listen("tcp", config.Agent.Port)
+		if err != nil {
+			log.Fatalf("Failed to start agent listener: %v", err)
+		}
+		logInfo("Agent check listening on %s", config.Agent.Port)
+		go func() {
+			if err := serveAgent(agentListener); err != nil && !errors.Is(err, net.ErrClosed) {
+				logError("Agent listener stopped: %v", err)
+			}
+		}()
 	}
 
 	// Setup HTTP handlers
 	http.HandleFunc("/health", healthHandler)
+	http.HandleFunc("/metrics", prometheusHandler)
+	http.HandleFunc("/admin/drain", adminModeHandler(adminModeDrain))
+	http.HandleFunc("/admin/maintenance", adminModeHandler(adminModeMaintenance))
+	http.HandleFunc("/admin/clear", adminClearHandler)
 
 	// Start HTTP server
-	logInfo("Probe listening on %s", config.Server.Port)
-	if err := http.ListenAndServe(config.Server.Port, nil); err != nil {
+	listener, err := net.Listen("tcp", config.Server.Port)
+	if err != nil {
 		log.Fatalf("Failed to start server: %v", err)
 	}
+	server := &http.Server{Addr: config.Server.Port}
+	serverErr := make(chan error, 1)
+	go func() {
+		serverErr <- server.Serve(listener)
+	}()
+	logInfo("Probe listening on %s", config.Server.Port)
+
+	exitCode := 0
+	select {
+	case sig := <-signals:
+		shutdown(sig, signals, server, agentListener, registry, stopCollectors)
+	case err := <-serverErr:
+		logError("HTTP server stopped: %v", err)
+		exitCode = 1
+	}
+
+	if err := closeLogging(logFile); err != nil {
+		log.Printf("Warning: Failed to close log file: %v", err)
+	}
+	os.Exit(exitCode)
 }
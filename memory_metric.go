@@ -1,79 +1,236 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
-	"log"
 	"os"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 )
 
+// memoryStats holds memory and swap usage percentages
+type memoryStats struct {
+	Used     float64 // Percentage of memory not available for new allocations
+	SwapUsed float64 // Percentage of swap in use, 0 without swap
+}
+
+// vmstatRates holds paging activity between two /proc/vmstat readings
+type vmstatRates struct {
+	SwapIn      float64 // Pages swapped in per second
+	SwapOut     float64 // Pages swapped out per second
+	MajorFaults float64 // Major page faults per second
+	OOMKills    float64 // Processes killed by the OOM killer since the previous reading
+	HasOOMKill  bool    // Kernel exposes the oom_kill counter (4.13+)
+	Stale       bool    // No valid rates: first reading or counters reset
+}
+
+// vmstatSnapshot stores previous /proc/vmstat counters for delta calculation
+type vmstatSnapshot struct {
+	counters  map[string]uint64
+	timestamp time.Time
+}
+
+var (
+	vmstatCache      *vmstatSnapshot
+	vmstatCacheMutex sync.Mutex
+)
+
+// parseMeminfo parses the content of /proc/meminfo into values keyed by field name.
+// Format: MemTotal:       16314540 kB
+// Values are kept in the unit of the file (kB for sizes, pages for HugePages counts).
+func parseMeminfo(data string) (map[string]uint64, error) {
+	values := make(map[string]uint64)
+
+	for _, line := range strings.Split(data, "\n") {
+		key, rest, found := strings.Cut(line, ":")
+		if !found {
+			continue
+		}
+
+		fields := strings.Fields(rest)
+		if len(fields) == 0 {
+			return nil, fmt.Errorf("%s: missing value", key)
+		}
+		value, err := strconv.ParseUint(fields[0], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", key, err)
+		}
+		values[key] = value
+	}
+
+	return values, nil
+}
+
+// parseCounters parses "name value" lines as found in /proc/vmstat and
+// cgroup stat files such as cpu.stat or memory.events.
+// Format: pgmajfault 322
+func parseCounters(data string) (map[string]uint64, error) {
+	counters := make(map[string]uint64)
+
+	for _, line := range strings.Split(data, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("invalid line %q", line)
+		}
+
+		value, err := strconv.ParseUint(fields[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", fields[0], err)
+		}
+		counters[fields[0]] = value
+	}
+
+	return counters, nil
+}
+
+// memoryStatsFromMeminfo computes memory and swap usage from parsed /proc/meminfo values
+func memoryStatsFromMeminfo(meminfo map[string]uint64) (memoryStats, error) {
+	memTotal := meminfo["MemTotal"]
+	memAvailable, exists := meminfo["MemAvailable"]
+	if memTotal == 0 || !exists {
+		return memoryStats{}, fmt.Errorf("failed to parse memory information")
+	}
+
+	stats := memoryStats{
+		Used: float64(memTotal-memAvailable) / float64(memTotal) * 100.0,
+	}
+
+	if swapTotal := meminfo["SwapTotal"]; swapTotal > 0 {
+		stats.SwapUsed = float64(swapTotal-meminfo["SwapFree"]) / float64(swapTotal) * 100.0
+	}
+
+	return stats, nil
+}
+
+// getMemoryStats reads memory and swap usage from /proc/meminfo
+func getMemoryStats() (memoryStats, error) {
+	data, err := os.ReadFile(procPath("meminfo"))
+	if err != nil {
+		return memoryStats{}, err
+	}
+
+	meminfo, err := parseMeminfo(string(data))
+	if err != nil {
+		return memoryStats{}, err
+	}
+
+	return memoryStatsFromMeminfo(meminfo)
+}
+
 // getMemoryUsage reads memory usage from /proc/meminfo
 // Returns percentage of memory used
 func getMemoryUsage() (float64, error) {
-	data, err := os.ReadFile("/proc/meminfo")
-	if err != nil {
-		return 0, err
+	stats, err := getMemoryStats()
+	return stats.Used, err
+}
+
+// vmstatDelta computes paging rates between two /proc/vmstat readings taken elapsed seconds apart.
+// The rates are stale when no time elapsed or a counter went backwards.
+func vmstatDelta(previous, current map[string]uint64, elapsed float64) vmstatRates {
+	_, hasOOMKill := current["oom_kill"]
+
+	deltas := counterDeltas{elapsed: elapsed}
+	rates := vmstatRates{
+		SwapIn:      deltas.rate(previous["pswpin"], current["pswpin"]),
+		SwapOut:     deltas.rate(previous["pswpout"], current["pswpout"]),
+		MajorFaults: deltas.rate(previous["pgmajfault"], current["pgmajfault"]),
+		OOMKills:    deltas.delta(previous["oom_kill"], current["oom_kill"]),
+		HasOOMKill:  hasOOMKill,
+	}
+	if deltas.stale() {
+		return vmstatRates{HasOOMKill: hasOOMKill, Stale: true}
 	}
 
-	var memTotal, memAvailable uint64
-	lines := string(data)
+	return rates
+}
 
-	// Parse MemTotal and MemAvailable
-	_, err = fmt.Sscanf(lines, "MemTotal: %d kB\nMemFree: %*d kB\nMemAvailable: %d kB", &memTotal, &memAvailable)
+// getVMStatRates reads /proc/vmstat and returns paging activity since the previous reading.
+// The first reading returns stale rates.
+func getVMStatRates() (vmstatRates, error) {
+	data, err := os.ReadFile(procPath("vmstat"))
 	if err != nil {
-		// Try alternative parsing
-		for i := 0; i < len(lines); i++ {
-			if lines[i:i+9] == "MemTotal:" {
-				fmt.Sscanf(lines[i:], "MemTotal: %d kB", &memTotal)
-			}
-			if i+13 <= len(lines) && lines[i:i+13] == "MemAvailable:" {
-				fmt.Sscanf(lines[i:], "MemAvailable: %d kB", &memAvailable)
-				break
-			}
-		}
+		return vmstatRates{}, err
+	}
+
+	counters, err := parseCounters(string(data))
+	if err != nil {
+		return vmstatRates{}, err
 	}
+	currentTime := time.Now()
 
-	if memTotal == 0 {
-		return 0, fmt.Errorf("failed to parse memory information")
+	vmstatCacheMutex.Lock()
+	defer vmstatCacheMutex.Unlock()
+
+	previous := vmstatCache
+	vmstatCache = &vmstatSnapshot{counters: counters, timestamp: currentTime}
+
+	if previous == nil {
+		// First reading, no rate yet
+		return vmstatDelta(counters, counters, 0), nil
 	}
 
-	memUsed := memTotal - memAvailable
-	memPercent := float64(memUsed) / float64(memTotal) * 100.0
+	return vmstatDelta(previous.counters, counters, currentTime.Sub(previous.timestamp).Seconds()), nil
+}
 
-	return memPercent, nil
+// memoryCollector reports memory and swap usage, paging activity and OOM kills
+type memoryCollector struct{}
+
+// Name identifies the collector in logs
+func (c *memoryCollector) Name() string {
+	return "memory"
 }
 
-// collectMemoryMetric runs as a goroutine to collect memory metrics
-func collectMemoryMetric() {
-	for {
-		memUsage, err := getMemoryUsage()
-		if err != nil {
-			log.Printf("Error collecting memory metric: %v", err)
-			memUsage = 0
-		}
+// Interval is the delay between two memory collections
+func (c *memoryCollector) Interval() time.Duration {
+	return collectorInterval(c.Name(), 2*time.Second)
+}
 
-		// Apply warmup factor if enabled
-		effectiveMax := config.Thresholds.MaxMemory
-		if config.Warmup.Enabled {
-			warmupFactor := getWarmupFactor()
-			effectiveMax = config.Thresholds.MaxMemory * warmupFactor
-		}
+// Collect reads memory usage and paging counters. Memory and swap usage are
+// checked against config.Thresholds.MaxMemory and MaxSwap; any OOM kill since
+// the previous collection is KO.
+func (c *memoryCollector) Collect(ctx context.Context) ([]Sample, error) {
+	cfg := currentConfig()
+	var errs []error
 
-		// Determine status
-		status := "OK"
-		if memUsage > effectiveMax {
-			status = "KO"
-		}
+	stats, statsErr := getMemoryStats()
+	if statsErr != nil {
+		errs = append(errs, fmt.Errorf("meminfo: %w", statsErr))
+	}
 
-		// Update cache
-		cacheMutex.Lock()
-		metricCache["memory"] = MetricStatus{
-			Current: memUsage,
-			Max:     effectiveMax,
-			Status:  status,
-		}
-		cacheMutex.Unlock()
+	rates, ratesErr := getVMStatRates()
+	if ratesErr != nil {
+		errs = append(errs, fmt.Errorf("vmstat: %w", ratesErr))
+		rates = vmstatRates{HasOOMKill: true}
+	}
 
-		time.Sleep(2 * time.Second)
+	usage := []Sample{
+		// In cgroup mode, max_memory applies to cgroup_memory instead of the host
+		{Name: "memory", Value: stats.Used, Max: cfg.Thresholds.MaxMemory, NoMax: cfg.Cgroup.Enabled},
+		{Name: "memory_swap", Value: stats.SwapUsed, Max: cfg.Thresholds.MaxSwap, NoMax: cfg.Thresholds.MaxSwap <= 0},
 	}
+	if statsErr != nil {
+		failSamples(usage, statsErr)
+	}
+
+	paging := []Sample{
+		{Name: "memory_swap_in", Value: rates.SwapIn, NoMax: true, Stale: rates.Stale},
+		{Name: "memory_swap_out", Value: rates.SwapOut, NoMax: true, Stale: rates.Stale},
+		{Name: "memory_major_faults", Value: rates.MajorFaults, NoMax: true, Stale: rates.Stale},
+	}
+	if rates.HasOOMKill {
+		paging = append(paging, Sample{Name: "memory_oom_kills", Value: rates.OOMKills, Max: 0, Stale: rates.Stale})
+	} else {
+		paging = append(paging, Sample{Name: "memory_oom_kills", Unsupported: true})
+	}
+	if ratesErr != nil {
+		failSamples(paging, ratesErr)
+	}
+
+	return append(usage, paging...), errors.Join(errs...)
 }
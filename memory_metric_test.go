@@ -1,18 +1,35 @@
 package main
 
 import (
+	"context"
 	"testing"
 	"time"
 )
 
 func TestGetMemoryUsage(t *testing.T) {
-	usage, err := getMemoryUsage()
-	if err != nil {
-		t.Fatalf("getMemoryUsage() returned error: %v", err)
+	oldConfig := config
+	defer func() { config = oldConfig }()
+
+	tests := []struct {
+		fixture string
+		want    float64
+	}{
+		{fixture: "idle", want: 25},
+		{fixture: "busy", want: 95},
+		{fixture: "legacy", want: 25},
 	}
 
-	if usage < 0 || usage > 100 {
-		t.Errorf("getMemoryUsage() = %v, want value between 0 and 100", usage)
+	for _, tt := range tests {
+		t.Run(tt.fixture, func(t *testing.T) {
+			useFixture(tt.fixture)
+			usage, err := getMemoryUsage()
+			if err != nil {
+				t.Fatalf("getMemoryUsage() returned error: %v", err)
+			}
+			if usage != tt.want {
+				t.Errorf("getMemoryUsage() = %v, want %v", usage, tt.want)
+			}
+		})
 	}
 }
 
@@ -80,24 +97,11 @@ func TestCollectMemoryMetricUpdatesCache(t *testing.T) {
 	cacheMutex.Unlock()
 
 	// Run one iteration of metric collection
-	memUsage, err := getMemoryUsage()
+	samples, err := (&memoryCollector{}).Collect(context.Background())
 	if err != nil {
-		memUsage = 0
-	}
-
-	effectiveMax := config.Thresholds.MaxMemory
-	status := "OK"
-	if memUsage > effectiveMax {
-		status = "KO"
+		t.Fatalf("memoryCollector.Collect() returned error: %v", err)
 	}
-
-	cacheMutex.Lock()
-	metricCache["memory"] = MetricStatus{
-		Current: memUsage,
-		Max:     effectiveMax,
-		Status:  status,
-	}
-	cacheMutex.Unlock()
+	publishSamples(samples)
 
 	// Verify cache was updated
 	cacheMutex.RLock()
@@ -119,4 +123,183 @@ func TestCollectMemoryMetricUpdatesCache(t *testing.T) {
 	if metric.Status != "OK" && metric.Status != "KO" {
 		t.Errorf("Memory metric status = %v, want OK or KO", metric.Status)
 	}
+
+	cacheMutex.RLock()
+	_, swapExists := metricCache["memory_swap"]
+	_, oomExists := metricCache["memory_oom_kills"]
+	cacheMutex.RUnlock()
+
+	if !swapExists || !oomExists {
+		t.Error("Swap or OOM kill metric not found in cache")
+	}
+}
+
+func TestParseMeminfo(t *testing.T) {
+	data := `MemTotal:       16000000 kB
+MemFree:         2000000 kB
+MemAvailable:    4000000 kB
+SwapTotal:       2000000 kB
+SwapFree:        1500000 kB
+HugePages_Total:       0
+`
+
+	meminfo, err := parseMeminfo(data)
+	if err != nil {
+		t.Fatalf("parseMeminfo() returned error: %v", err)
+	}
+
+	expected := map[string]uint64{
+		"MemTotal":        16000000,
+		"MemFree":         2000000,
+		"MemAvailable":    4000000,
+		"SwapTotal":       2000000,
+		"SwapFree":        1500000,
+		"HugePages_Total": 0,
+	}
+	for key, value := range expected {
+		if meminfo[key] != value {
+			t.Errorf("parseMeminfo()[%s] = %d, want %d", key, meminfo[key], value)
+		}
+	}
+
+	if _, err := parseMeminfo("MemTotal: abc kB\n"); err == nil {
+		t.Error("parseMeminfo() expected error for invalid value")
+	}
+}
+
+func TestMemoryStatsFromMeminfo(t *testing.T) {
+	tests := []struct {
+		name     string
+		meminfo  map[string]uint64
+		expected memoryStats
+		wantErr  bool
+	}{
+		{
+			name:     "memory and swap",
+			meminfo:  map[string]uint64{"MemTotal": 16000, "MemAvailable": 4000, "SwapTotal": 2000, "SwapFree": 1500},
+			expected: memoryStats{Used: 75, SwapUsed: 25},
+		},
+		{
+			name:     "no swap",
+			meminfo:  map[string]uint64{"MemTotal": 1000, "MemAvailable": 900, "SwapTotal": 0, "SwapFree": 0},
+			expected: memoryStats{Used: 10},
+		},
+		{
+			name:    "missing MemAvailable",
+			meminfo: map[string]uint64{"MemTotal": 1000},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stats, err := memoryStatsFromMeminfo(tt.meminfo)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("memoryStatsFromMeminfo() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if stats != tt.expected {
+				t.Errorf("memoryStatsFromMeminfo() = %+v, want %+v", stats, tt.expected)
+			}
+		})
+	}
+}
+
+func TestVMStatDelta(t *testing.T) {
+	previous, err := parseCounters("pswpin 100\npswpout 200\npgmajfault 1000\noom_kill 3\n")
+	if err != nil {
+		t.Fatalf("parseCounters() returned error: %v", err)
+	}
+	current, err := parseCounters("pswpin 140\npswpout 200\npgmajfault 1500\noom_kill 4\n")
+	if err != nil {
+		t.Fatalf("parseCounters() returned error: %v", err)
+	}
+
+	rates := vmstatDelta(previous, current, 2)
+	expected := vmstatRates{SwapIn: 20, SwapOut: 0, MajorFaults: 250, OOMKills: 1, HasOOMKill: true}
+	if rates != expected {
+		t.Errorf("vmstatDelta() = %+v, want %+v", rates, expected)
+	}
+
+	// Kernels before 4.13 have no oom_kill counter
+	old := map[string]uint64{"pswpin": 0, "pswpout": 0, "pgmajfault": 0}
+	if rates := vmstatDelta(old, old, 2); rates.HasOOMKill {
+		t.Error("vmstatDelta() reported oom_kill support without the counter")
+	}
+
+	// Counters lower than before are discarded instead of reported as huge rates
+	if rates := vmstatDelta(current, previous, 2); rates != (vmstatRates{HasOOMKill: true, Stale: true}) {
+		t.Errorf("vmstatDelta() after a counter reset = %+v, want stale", rates)
+	}
+}
+
+func TestMemoryCollectorFixtures(t *testing.T) {
+	oldConfig := config
+	config = Config{
+		startTime: time.Now(),
+	}
+	config.Thresholds.MaxMemory = 90.0
+	config.Thresholds.MaxSwap = 60.0
+	defer func() { config = oldConfig }()
+
+	vmstatCacheMutex.Lock()
+	vmstatCache = nil
+	vmstatCacheMutex.Unlock()
+
+	cacheMutex.Lock()
+	metricCache = make(map[string]MetricStatus)
+	cacheMutex.Unlock()
+
+	collector := &memoryCollector{}
+	steps := []struct {
+		fixture    string
+		want       map[string]float64
+		wantStatus map[string]string // OK unless listed
+	}{
+		{
+			// No OOM kill count before a second reading
+			fixture:    "idle",
+			want:       map[string]float64{"memory": 25, "memory_swap": 0, "memory_oom_kills": 0},
+			wantStatus: map[string]string{"memory_oom_kills": "STALE"},
+		},
+		{
+			// Memory above max_memory and an OOM kill since the idle snapshot
+			fixture:    "busy",
+			want:       map[string]float64{"memory": 95, "memory_swap": 50, "memory_oom_kills": 1},
+			wantStatus: map[string]string{"memory": "KO", "memory_oom_kills": "KO"},
+		},
+	}
+
+	for _, step := range steps {
+		useFixture(step.fixture)
+		samples, err := collector.Collect(context.Background())
+		if err != nil {
+			t.Fatalf("%s: memoryCollector.Collect() returned error: %v", step.fixture, err)
+		}
+		publishSamples(samples)
+
+		cacheMutex.RLock()
+		for name, want := range step.want {
+			metric := metricCache[name]
+			wantStatus := step.wantStatus[name]
+			if wantStatus == "" {
+				wantStatus = "OK"
+			}
+			if metric.Current != want || metric.Status != wantStatus {
+				t.Errorf("%s: %s = %v (%s), want %v (%s)", step.fixture, name, metric.Current, metric.Status, want, wantStatus)
+			}
+		}
+		cacheMutex.RUnlock()
+	}
+
+	// Kernels before 4.13 have no oom_kill counter in /proc/vmstat
+	useFixture("legacy")
+	samples, err := collector.Collect(context.Background())
+	if err != nil {
+		t.Fatalf("legacy: memoryCollector.Collect() returned error: %v", err)
+	}
+	for _, sample := range samples {
+		if sample.Name == "memory_oom_kills" && !sample.Unsupported {
+			t.Errorf("legacy: memory_oom_kills = %+v, want unsupported", sample)
+		}
+	}
 }
@@ -1,130 +1,214 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
-	"log"
 	"os"
+	"sort"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 )
 
-// bandwidthSnapshot stores previous bandwidth reading for delta calculation
-type bandwidthSnapshot struct {
-	totalBytes uint64
-	timestamp  time.Time
+// interfaceCounters holds cumulative interface counters from /proc/net/dev
+type interfaceCounters struct {
+	rxBytes   uint64
+	rxPackets uint64
+	txBytes   uint64
+	txPackets uint64
+}
+
+// interfaceRates holds per-second traffic rates of an interface
+type interfaceRates struct {
+	RxBytes   float64
+	TxBytes   float64
+	RxPackets float64
+	TxPackets float64
+	Stale     bool // No valid rates: first reading, counter reset or missing interface
+}
+
+// interfaceSnapshot stores previous interface counters for delta calculation
+type interfaceSnapshot struct {
+	counters  interfaceCounters
+	timestamp time.Time
 }
 
 var (
-	bandwidthCache      = make(map[string]bandwidthSnapshot)
-	bandwidthCacheMutex sync.Mutex
+	interfaceCache      = make(map[string]interfaceSnapshot)
+	interfaceCacheMutex sync.Mutex
 )
 
 // getNetworkConnections reads the number of active network connections
 // Returns count of established TCP connections
 func getNetworkConnections() (float64, error) {
-	// Read /proc/net/tcp for IPv4 connections
-	data, err := os.ReadFile("/proc/net/tcp")
+	sockets, err := readTCPSockets()
 	if err != nil {
 		return 0, err
 	}
+	return countTCPStates(sockets)["established"], nil
+}
 
-	// Count lines with state 01 (ESTABLISHED)
-	// Format: sl  local_address rem_address   st ...
-	lines := string(data)
-	count := 0
-	lineStart := 0
-
-	for i := 0; i < len(lines); i++ {
-		if lines[i] == '\n' {
-			line := lines[lineStart:i]
-			// Check if line contains " 01 " which indicates ESTABLISHED state
-			for j := 0; j < len(line)-3; j++ {
-				if line[j:j+4] == " 01 " {
-					count++
-					break
-				}
-			}
-			lineStart = i + 1
-		}
+// networkCollector reports TCP connection states and per-interface traffic
+type networkCollector struct{}
+
+// Name identifies the collector in logs
+func (c *networkCollector) Name() string {
+	return "network"
+}
+
+// Interval is the delay between two network collections
+func (c *networkCollector) Interval() time.Duration {
+	return collectorInterval(c.Name(), 2*time.Second)
+}
+
+// Collect returns the established connection count, checked against config.Thresholds.MaxConnections,
+// the count of every TCP state, checked against config.Thresholds.MaxTCPStates,
+// the load of every port in config.Monitoring.Ports and the rx/tx rates of every interface in config.Monitoring.NetworkInterfaces
+func (c *networkCollector) Collect(ctx context.Context) ([]Sample, error) {
+	cfg := currentConfig()
+	var errs []error
+
+	// First collect global TCP socket states
+	sockets, err := readTCPSockets()
+	if err != nil {
+		errs = append(errs, fmt.Errorf("tcp sockets: %w", err))
 	}
+	states := countTCPStates(sockets)
 
-	// Also check IPv6 connections
-	data6, err := os.ReadFile("/proc/net/tcp6")
-	if err == nil {
-		lines6 := string(data6)
-		lineStart = 0
-		for i := 0; i < len(lines6); i++ {
-			if lines6[i] == '\n' {
-				line := lines6[lineStart:i]
-				for j := 0; j < len(line)-3; j++ {
-					if line[j:j+4] == " 01 " {
-						count++
-						break
-					}
-				}
-				lineStart = i + 1
-			}
-		}
+	samples := []Sample{
+		{Name: "network_connections", Value: states["established"], Max: cfg.Thresholds.MaxConnections, Err: err},
 	}
 
-	return float64(count), nil
-}
+	// One sample per TCP state, sorted for a stable order
+	stateNames := make([]string, 0, len(states))
+	for state := range states {
+		stateNames = append(stateNames, state)
+	}
+	sort.Strings(stateNames)
 
-// collectNetworkMetric runs as a goroutine to collect network metrics
-// Monitors all interfaces specified in config.NetworkInterfaces against config.MaxConnections
-func collectNetworkMetric() {
-	for {
-		// First collect global connection count
-		connections, err := getNetworkConnections()
+	for _, state := range stateNames {
+		maxCount := cfg.Thresholds.MaxTCPStates[state]
+		samples = append(samples, Sample{
+			Name:   "network_tcp_" + state,
+			Value:  states[state],
+			Max:    maxCount,
+			NoMax:  maxCount <= 0,
+			Err:    err,
+			Metric: "network_tcp_state",
+			Labels: map[string]string{"state": state},
+		})
+	}
+
+	// Per-port load for the services behind the load balancer
+	for _, monitored := range cfg.Monitoring.Ports {
+		counts := countPortSockets(sockets, monitored.Port)
+		portSamples := []Sample{
+			portSample(monitored.Port, "connections", counts.Established, monitored.MaxConnections),
+			portSample(monitored.Port, "syn_recv", counts.SynRecv, monitored.MaxSynRecv),
+			portSample(monitored.Port, "accept_queue", counts.AcceptQueue, monitored.MaxAcceptQueue),
+		}
 		if err != nil {
-			log.Printf("Error collecting network connections: %v", err)
-			connections = 0
+			failSamples(portSamples, err)
 		}
+		samples = append(samples, portSamples...)
+	}
 
-		// Apply warmup factor if enabled
-		effectiveMax := config.Thresholds.MaxConnections
-		if config.Warmup.Enabled {
-			warmupFactor := getWarmupFactor()
-			effectiveMax = config.Thresholds.MaxConnections * warmupFactor
+	// Check each network interface for traffic
+	for _, monitored := range cfg.Monitoring.NetworkInterfaces {
+		iface := monitored.Name
+		rates, ratesErr := getInterfaceRates(iface)
+		if ratesErr != nil {
+			errs = append(errs, fmt.Errorf("traffic for %s: %w", iface, ratesErr))
 		}
 
-		// Determine status for connections
-		status := "OK"
-		if connections > effectiveMax {
-			status = "KO"
+		// Link speed is only needed for link-relative limits
+		linkSpeed := monitored.LinkSpeed
+		if linkSpeed == 0 && cfg.Thresholds.MaxLinkUtilization > 0 {
+			linkSpeed = getLinkSpeed(iface)
 		}
+		maxRxBytes, maxTxBytes := interfaceByteLimits(monitored, linkSpeed, cfg.Thresholds.MaxLinkUtilization)
 
-		// Update cache for global connections
-		cacheMutex.Lock()
-		metricCache["network_connections"] = MetricStatus{
-			Current: connections,
-			Max:     effectiveMax,
-			Status:  status,
+		labels := map[string]string{"interface": iface}
+		ifaceSamples := []Sample{
+			interfaceSample(iface, "rx_bytes", rates.RxBytes, maxRxBytes, rates.Stale, labels),
+			interfaceSample(iface, "tx_bytes", rates.TxBytes, maxTxBytes, rates.Stale, labels),
+			interfaceSample(iface, "rx_packets", rates.RxPackets, monitored.MaxRxPackets, rates.Stale, labels),
+			interfaceSample(iface, "tx_packets", rates.TxPackets, monitored.MaxTxPackets, rates.Stale, labels),
 		}
-		cacheMutex.Unlock()
+		// A missing interface is unknown rather than idle
+		if ratesErr != nil {
+			failSamples(ifaceSamples, ratesErr)
+		}
+		samples = append(samples, ifaceSamples...)
+	}
 
-		// Check each network interface for traffic
-		for _, iface := range config.Monitoring.NetworkInterfaces {
-			bytesPerSec, err := getNetworkBandwidth(iface)
-			if err != nil {
-				log.Printf("Error collecting network bandwidth for %s: %v", iface, err)
-				bytesPerSec = 0
-			}
+	// Forget traffic history of interfaces no longer monitored
+	pruneInterfaceCache(cfg.Monitoring.NetworkInterfaces)
 
-			// For bandwidth, we report bytes/sec
-			// Status is always OK unless we add a bandwidth threshold later
-			metricName := fmt.Sprintf("network_%s_bandwidth", iface)
-			cacheMutex.Lock()
-			metricCache[metricName] = MetricStatus{
-				Current: bytesPerSec,
-				Max:     0, // No max threshold for bandwidth yet
-				Status:  "OK",
-			}
-			cacheMutex.Unlock()
-		}
+	return samples, errors.Join(errs...)
+}
+
+// portSample builds a per-port sample; a zero max means no limit
+func portSample(port uint16, kind string, value, max float64) Sample {
+	return Sample{
+		Name:   fmt.Sprintf("network_port_%d_%s", port, kind),
+		Value:  value,
+		Max:    max,
+		NoMax:  max <= 0,
+		Metric: "network_port_" + kind,
+		Labels: map[string]string{"port": strconv.Itoa(int(port))},
+	}
+}
+
+// interfaceSample builds a per-interface rate sample; a zero max means no limit
+func interfaceSample(iface, kind string, value, max float64, stale bool, labels map[string]string) Sample {
+	return Sample{
+		Name:   fmt.Sprintf("network_%s_%s", iface, kind),
+		Value:  value,
+		Max:    max,
+		NoMax:  max <= 0,
+		Stale:  stale,
+		Metric: "network_" + kind,
+		Labels: labels,
+	}
+}
+
+// interfaceByteLimits returns the rx and tx bytes/sec limits of an interface.
+// Absolute limits from the interface entry take precedence over a limit
+// relative to the link speed (in Mbit/s). Zero means no limit.
+func interfaceByteLimits(iface NetworkInterface, linkSpeed, maxUtilization float64) (float64, float64) {
+	linkMax := 0.0
+	if linkSpeed > 0 && maxUtilization > 0 {
+		// Mbit/s to bytes/sec, scaled by the allowed utilization
+		linkMax = linkSpeed * 1000000 / 8 * maxUtilization / 100.0
+	}
 
-		time.Sleep(2 * time.Second)
+	maxRx, maxTx := linkMax, linkMax
+	if iface.MaxRxBytes > 0 {
+		maxRx = iface.MaxRxBytes
 	}
+	if iface.MaxTxBytes > 0 {
+		maxTx = iface.MaxTxBytes
+	}
+
+	return maxRx, maxTx
+}
+
+// getLinkSpeed reads the link speed of an interface in Mbit/s from sysfs.
+// Returns 0 when the speed is unknown, e.g. for loopback or virtual interfaces.
+func getLinkSpeed(iface string) float64 {
+	data, err := os.ReadFile(sysPath("class", "net", iface, "speed"))
+	if err != nil {
+		return 0
+	}
+
+	speed, err := strconv.ParseFloat(strings.TrimSpace(string(data)), 64)
+	if err != nil || speed <= 0 {
+		return 0
+	}
+	return speed
 }
 
 // formatBandwidth converts bytes/sec to human-readable ISO format
@@ -149,66 +233,107 @@ func formatBandwidth(bytesPerSec float64) string {
 	return fmt.Sprintf("%.0f", bytesPerSec)
 }
 
-// getNetworkBandwidth reads network bandwidth usage from /proc/net/dev
-// Returns bytes/sec for the specified interface by calculating delta from last reading
-func getNetworkBandwidth(iface string) (float64, error) {
-	data, err := os.ReadFile("/proc/net/dev")
-	if err != nil {
-		return 0, err
-	}
+// parseNetDev extracts the counters of iface from /proc/net/dev content
+func parseNetDev(data, iface string) (interfaceCounters, error) {
+	for _, line := range strings.Split(data, "\n") {
+		// Interface names are right-aligned: "    lo: 1234 ..."
+		name, values, found := strings.Cut(line, ":")
+		if !found || strings.TrimSpace(name) != iface {
+			continue
+		}
 
-	lines := string(data)
-	var rxBytes, txBytes uint64
-
-	// Find the interface line
-	lineStart := 0
-	for i := 0; i < len(lines); i++ {
-		if lines[i] == '\n' {
-			line := lines[lineStart:i]
-			// Check if line starts with interface name
-			if len(line) > len(iface) && line[:len(iface)+1] == iface+":" {
-				// Parse bytes received and transmitted
-				_, err := fmt.Sscanf(line[len(iface)+1:], "%d %*d %*d %*d %*d %*d %*d %*d %d", &rxBytes, &txBytes)
-				if err != nil {
-					return 0, err
-				}
-				break
+		// Receive: bytes packets errs drop fifo frame compressed multicast
+		// Transmit: bytes packets errs drop fifo colls carrier compressed
+		fields := strings.Fields(values)
+		if len(fields) < 10 {
+			return interfaceCounters{}, fmt.Errorf("unexpected format for interface %s", iface)
+		}
+
+		var counters interfaceCounters
+		targets := map[int]*uint64{
+			0: &counters.rxBytes,
+			1: &counters.rxPackets,
+			8: &counters.txBytes,
+			9: &counters.txPackets,
+		}
+		for index, target := range targets {
+			value, err := strconv.ParseUint(fields[index], 10, 64)
+			if err != nil {
+				return interfaceCounters{}, fmt.Errorf("invalid counter for interface %s: %w", iface, err)
 			}
-			lineStart = i + 1
+			*target = value
 		}
+
+		return counters, nil
 	}
 
-	currentTotalBytes := rxBytes + txBytes
-	currentTime := time.Now()
+	return interfaceCounters{}, fmt.Errorf("interface %s not found", iface)
+}
 
-	// Calculate bytes/sec using delta
-	bandwidthCacheMutex.Lock()
-	defer bandwidthCacheMutex.Unlock()
+// getInterfaceRates reads interface counters from /proc/net/dev
+// Returns rx/tx bytes/sec and packets/sec by calculating delta from last reading.
+// Rates are stale on the first reading, after a counter reset and while the
+// interface is missing; a reappearing interface starts from a fresh baseline.
+func getInterfaceRates(iface string) (interfaceRates, error) {
+	data, err := os.ReadFile(procPath("net", "dev"))
+	if err != nil {
+		return interfaceRates{Stale: true}, err
+	}
 
-	snapshot, exists := bandwidthCache[iface]
-	if !exists {
-		// First reading, store it and return 0
-		bandwidthCache[iface] = bandwidthSnapshot{
-			totalBytes: currentTotalBytes,
-			timestamp:  currentTime,
-		}
-		return 0, nil
+	counters, err := parseNetDev(string(data), iface)
+	if err != nil {
+		interfaceCacheMutex.Lock()
+		delete(interfaceCache, iface)
+		interfaceCacheMutex.Unlock()
+		return interfaceRates{Stale: true}, err
 	}
+	currentTime := time.Now()
+
+	// Calculate rates using delta
+	interfaceCacheMutex.Lock()
+	defer interfaceCacheMutex.Unlock()
 
-	// Calculate delta
-	bytesDelta := currentTotalBytes - snapshot.totalBytes
-	timeDelta := currentTime.Sub(snapshot.timestamp).Seconds()
+	snapshot, exists := interfaceCache[iface]
 
 	// Update cache with current reading
-	bandwidthCache[iface] = bandwidthSnapshot{
-		totalBytes: currentTotalBytes,
-		timestamp:  currentTime,
+	interfaceCache[iface] = interfaceSnapshot{
+		counters:  counters,
+		timestamp: currentTime,
+	}
+
+	if !exists {
+		// First reading, no rate yet
+		return interfaceRates{Stale: true}, nil
 	}
 
-	if timeDelta <= 0 {
-		return 0, nil
+	deltas := counterDeltas{elapsed: currentTime.Sub(snapshot.timestamp).Seconds()}
+	rates := interfaceRates{
+		RxBytes:   deltas.rate(snapshot.counters.rxBytes, counters.rxBytes),
+		TxBytes:   deltas.rate(snapshot.counters.txBytes, counters.txBytes),
+		RxPackets: deltas.rate(snapshot.counters.rxPackets, counters.rxPackets),
+		TxPackets: deltas.rate(snapshot.counters.txPackets, counters.txPackets),
+	}
+	if deltas.stale() {
+		// Re-created interface or 32-bit counter wrap, the reading is only a new baseline
+		return interfaceRates{Stale: true}, nil
 	}
 
-	bytesPerSec := float64(bytesDelta) / timeDelta
-	return bytesPerSec, nil
+	return rates, nil
+}
+
+// pruneInterfaceCache removes snapshots of interfaces that are not in ifaces
+func pruneInterfaceCache(ifaces []NetworkInterface) {
+	monitored := make(map[string]bool, len(ifaces))
+	for _, iface := range ifaces {
+		monitored[iface.Name] = true
+	}
+
+	interfaceCacheMutex.Lock()
+	defer interfaceCacheMutex.Unlock()
+
+	for iface := range interfaceCache {
+		if !monitored[iface] {
+			delete(interfaceCache, iface)
+		}
+	}
 }
@@ -1,47 +1,223 @@
 package main
 
 import (
+	"context"
+	"math"
+	"os"
+	"path/filepath"
 	"testing"
 	"time"
 )
 
 func TestGetNetworkConnections(t *testing.T) {
-	connections, err := getNetworkConnections()
-	if err != nil {
-		t.Fatalf("getNetworkConnections() returned error: %v", err)
+	oldConfig := config
+	defer func() { config = oldConfig }()
+
+	tests := []struct {
+		fixture string
+		want    float64
+	}{
+		{fixture: "idle", want: 2},
+		{fixture: "busy", want: 4},
+		{fixture: "legacy", want: 2}, // IPv6 disabled, no /proc/net/tcp6
 	}
 
-	if connections < 0 {
-		t.Errorf("getNetworkConnections() = %v, want non-negative value", connections)
+	for _, tt := range tests {
+		t.Run(tt.fixture, func(t *testing.T) {
+			useFixture(tt.fixture)
+			connections, err := getNetworkConnections()
+			if err != nil {
+				t.Fatalf("getNetworkConnections() returned error: %v", err)
+			}
+			if connections != tt.want {
+				t.Errorf("getNetworkConnections() = %v, want %v", connections, tt.want)
+			}
+		})
 	}
 }
 
-func TestGetNetworkBandwidth(t *testing.T) {
-	// Clear bandwidth cache for clean test
-	bandwidthCacheMutex.Lock()
-	bandwidthCache = make(map[string]bandwidthSnapshot)
-	bandwidthCacheMutex.Unlock()
+func TestGetLinkSpeed(t *testing.T) {
+	oldConfig := config
+	defer func() { config = oldConfig }()
 
-	// Test with loopback interface which should always exist
-	// First call should return 0 (no previous reading)
-	bandwidth1, err := getNetworkBandwidth("lo")
-	if err != nil {
-		t.Fatalf("getNetworkBandwidth() returned error: %v", err)
+	useFixture("idle")
+	if speed := getLinkSpeed("eth0"); speed != 1000 {
+		t.Errorf("getLinkSpeed(eth0) = %v, want 1000", speed)
+	}
+	if speed := getLinkSpeed("lo"); speed != 0 {
+		t.Errorf("getLinkSpeed(lo) = %v, want 0 without a speed file", speed)
+	}
+}
+
+func TestGetInterfaceRates(t *testing.T) {
+	oldConfig := config
+	defer func() { config = oldConfig }()
+
+	// Clear interface cache for clean test
+	interfaceCacheMutex.Lock()
+	interfaceCache = make(map[string]interfaceSnapshot)
+	interfaceCacheMutex.Unlock()
+
+	// Pretend the previous readings are 10 seconds old
+	age := func() {
+		interfaceCacheMutex.Lock()
+		defer interfaceCacheMutex.Unlock()
+		for iface, snapshot := range interfaceCache {
+			snapshot.timestamp = snapshot.timestamp.Add(-10 * time.Second)
+			interfaceCache[iface] = snapshot
+		}
+	}
+
+	steps := []struct {
+		fixture   string
+		iface     string
+		wantRxB   float64
+		wantStale bool
+		wantErr   bool
+	}{
+		// First reading has no rate yet
+		{fixture: "idle", iface: "eth0", wantStale: true},
+		{fixture: "idle", iface: "veth1", wantStale: true},
+		{fixture: "busy", iface: "eth0", wantRxB: 6000000},
+		{fixture: "busy", iface: "veth1", wantRxB: 850000},
+		// eth0 was re-created with fresh counters and veth1 is gone
+		{fixture: "reset", iface: "eth0", wantStale: true},
+		{fixture: "reset", iface: "veth1", wantStale: true, wantErr: true},
+		// eth0 counts from the reset baseline, veth1 reappears without history
+		{fixture: "busy", iface: "eth0", wantRxB: (61000000 - 3852) / 10.0},
+		{fixture: "busy", iface: "veth1", wantStale: true},
+	}
+
+	for i, step := range steps {
+		if i%2 == 0 {
+			age()
+		}
+		useFixture(step.fixture)
+
+		rates, err := getInterfaceRates(step.iface)
+		if (err != nil) != step.wantErr {
+			t.Fatalf("%s %s: getInterfaceRates() error = %v, wantErr %v", step.fixture, step.iface, err, step.wantErr)
+		}
+		if rates.Stale != step.wantStale {
+			t.Errorf("%s %s: getInterfaceRates() = %+v, want stale %v", step.fixture, step.iface, rates, step.wantStale)
+		}
+		if math.Abs(rates.RxBytes-step.wantRxB) > step.wantRxB*0.01 {
+			t.Errorf("%s %s: rx bytes/sec = %v, want about %v", step.fixture, step.iface, rates.RxBytes, step.wantRxB)
+		}
 	}
+}
+
+func TestParseNetDev(t *testing.T) {
+	data := `Inter-|   Receive                                                |  Transmit
+ face |bytes    packets errs drop fifo frame compressed multicast|bytes    packets errs drop fifo colls carrier compressed
+    lo: 16033799    2160    0    0    0     0          0         0 16033799    2160    0    0    0     0       0          0
+  eth0:    3852      59    0    0    0     0          0         0     5257      60    0    0    0     0       0          0
+ eth10:       1       2    0    0    0     0          0         0        3       4    0    0    0     0       0          0
+`
+
+	tests := []struct {
+		name    string
+		iface   string
+		want    interfaceCounters
+		wantErr bool
+	}{
+		{
+			name:  "loopback",
+			iface: "lo",
+			want:  interfaceCounters{rxBytes: 16033799, rxPackets: 2160, txBytes: 16033799, txPackets: 2160},
+		},
+		{
+			name:  "eth0 not confused with eth10",
+			iface: "eth0",
+			want:  interfaceCounters{rxBytes: 3852, rxPackets: 59, txBytes: 5257, txPackets: 60},
+		},
+		{
+			name:    "missing interface",
+			iface:   "eth1",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseNetDev(data, tt.iface)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseNetDev() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("parseNetDev() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
 
-	if bandwidth1 != 0 {
-		t.Errorf("getNetworkBandwidth() first call = %v, want 0", bandwidth1)
+func TestParseNetDevLegacyFixture(t *testing.T) {
+	// Older kernels print counters wider than the column right after the colon
+	data, err := os.ReadFile("testdata/legacy/proc/net/dev")
+	if err != nil {
+		t.Fatal(err)
 	}
 
-	// Wait a bit and call again to get actual delta
-	time.Sleep(100 * time.Millisecond)
-	bandwidth2, err := getNetworkBandwidth("lo")
+	counters, err := parseNetDev(string(data), "eth0")
 	if err != nil {
-		t.Fatalf("getNetworkBandwidth() second call returned error: %v", err)
+		t.Fatalf("parseNetDev() returned error: %v", err)
+	}
+	want := interfaceCounters{rxBytes: 4294967295, rxPackets: 3000000, txBytes: 123456789, txPackets: 2000000}
+	if counters != want {
+		t.Errorf("parseNetDev() = %+v, want %+v", counters, want)
+	}
+}
+
+func TestInterfaceByteLimits(t *testing.T) {
+	tests := []struct {
+		name           string
+		iface          NetworkInterface
+		linkSpeed      float64
+		maxUtilization float64
+		wantRx         float64
+		wantTx         float64
+	}{
+		{
+			name:           "link relative",
+			iface:          NetworkInterface{Name: "eth0"},
+			linkSpeed:      1000,
+			maxUtilization: 80,
+			wantRx:         100000000,
+			wantTx:         100000000,
+		},
+		{
+			name:           "absolute overrides link relative",
+			iface:          NetworkInterface{Name: "eth0", MaxTxBytes: 5000},
+			linkSpeed:      1000,
+			maxUtilization: 80,
+			wantRx:         100000000,
+			wantTx:         5000,
+		},
+		{
+			name:           "unknown link speed",
+			iface:          NetworkInterface{Name: "lo"},
+			linkSpeed:      0,
+			maxUtilization: 80,
+			wantRx:         0,
+			wantTx:         0,
+		},
+		{
+			name:           "link relative disabled",
+			iface:          NetworkInterface{Name: "eth0", MaxRxBytes: 2000},
+			linkSpeed:      1000,
+			maxUtilization: 0,
+			wantRx:         2000,
+			wantTx:         0,
+		},
 	}
 
-	if bandwidth2 < 0 {
-		t.Errorf("getNetworkBandwidth() = %v, want non-negative value", bandwidth2)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotRx, gotTx := interfaceByteLimits(tt.iface, tt.linkSpeed, tt.maxUtilization)
+			if gotRx != tt.wantRx || gotTx != tt.wantTx {
+				t.Errorf("interfaceByteLimits() = %v, %v, want %v, %v", gotRx, gotTx, tt.wantRx, tt.wantTx)
+			}
+		})
 	}
 }
 
@@ -149,55 +325,26 @@ func TestCollectNetworkMetricUpdatesCache(t *testing.T) {
 	config.Warmup.Enabled = false
 	config.Warmup.Duration = 60 * time.Second
 	config.Thresholds.MaxConnections = 1000.0
-	config.Monitoring.NetworkInterfaces = []string{"lo", "eth0"}
+	config.Monitoring.NetworkInterfaces = []NetworkInterface{{Name: "lo", MaxTxBytes: 1e12}, {Name: "eth0"}}
 	defer func() { config = oldConfig }()
 
-	// Clear cache
+	// Clear caches
 	cacheMutex.Lock()
 	metricCache = make(map[string]MetricStatus)
 	cacheMutex.Unlock()
 
-	// Run one iteration of metric collection for connections
-	connections, err := getNetworkConnections()
-	if err != nil {
-		connections = 0
-	}
-
-	effectiveMax := config.Thresholds.MaxConnections
-	status := "OK"
-	if connections > effectiveMax {
-		status = "KO"
-	}
-
-	cacheMutex.Lock()
-	metricCache["network_connections"] = MetricStatus{
-		Current: connections,
-		Max:     effectiveMax,
-		Status:  status,
-	}
-	cacheMutex.Unlock()
-
-	// Collect bandwidth for each interface
-	for _, iface := range config.Monitoring.NetworkInterfaces {
-		bytesPerSec, err := getNetworkBandwidth(iface)
-		if err != nil {
-			bytesPerSec = 0
-		}
+	interfaceCacheMutex.Lock()
+	interfaceCache = make(map[string]interfaceSnapshot)
+	interfaceCacheMutex.Unlock()
 
-		metricName := "network_" + iface + "_bandwidth"
-		cacheMutex.Lock()
-		metricCache[metricName] = MetricStatus{
-			Current: bytesPerSec,
-			Max:     0,
-			Status:  "OK",
-		}
-		cacheMutex.Unlock()
-	}
+	// Run one iteration of metric collection; eth0 may be missing on test hosts
+	samples, _ := (&networkCollector{}).Collect(context.Background())
+	publishSamples(samples)
 
 	// Verify cache was updated for connections
 	cacheMutex.RLock()
 	connMetric, connExists := metricCache["network_connections"]
-	loMetric, loExists := metricCache["network_lo_bandwidth"]
+	loMetric, loExists := metricCache["network_lo_rx_bytes"]
 	cacheMutex.RUnlock()
 
 	if !connExists {
@@ -225,7 +372,104 @@ func TestCollectNetworkMetricUpdatesCache(t *testing.T) {
 		t.Errorf("Network lo metric current = %v, want non-negative value", loMetric.Current)
 	}
 
-	if loMetric.Status != "OK" {
-		t.Errorf("Network lo metric status = %v, want OK", loMetric.Status)
+	// No rate exists before a second reading
+	if loMetric.Status != "STALE" {
+		t.Errorf("Network lo metric status = %v, want STALE", loMetric.Status)
+	}
+
+	// Loopback has no link speed, only the configured tx limit applies
+	cacheMutex.RLock()
+	loRx := metricCache["network_lo_rx_bytes"]
+	loTx := metricCache["network_lo_tx_bytes"]
+	_, loPacketsExists := metricCache["network_lo_rx_packets"]
+	cacheMutex.RUnlock()
+
+	if loRx.Max != 0 {
+		t.Errorf("Network lo rx max = %v, want 0 (no limit)", loRx.Max)
+	}
+	if loTx.Max != 1e12 {
+		t.Errorf("Network lo tx max = %v, want 1e12", loTx.Max)
+	}
+	if !loPacketsExists {
+		t.Error("Network lo rx packets metric not found in cache")
+	}
+}
+
+func TestDefaultConfigWithoutEth0(t *testing.T) {
+	oldConfig := config
+	defer func() { config = oldConfig }()
+
+	// A host with only a loopback interface
+	procRoot := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(procRoot, "net"), 0755); err != nil {
+		t.Fatalf("MkdirAll() returned error: %v", err)
+	}
+	for _, name := range []string{"tcp", "tcp6"} {
+		data, err := os.ReadFile(filepath.Join("testdata", "idle", "proc", "net", name))
+		if err != nil {
+			t.Fatalf("ReadFile() returned error: %v", err)
+		}
+		if err := os.WriteFile(filepath.Join(procRoot, "net", name), data, 0644); err != nil {
+			t.Fatalf("WriteFile() returned error: %v", err)
+		}
+	}
+	dev := "Inter-|   Receive                                                |  Transmit\n" +
+		" face |bytes    packets errs drop fifo frame compressed multicast|bytes    packets errs drop fifo colls carrier compressed\n" +
+		"    lo:    1000      10    0    0    0     0          0         0     1000      10    0    0    0     0       0          0\n"
+	if err := os.WriteFile(filepath.Join(procRoot, "net", "dev"), []byte(dev), 0644); err != nil {
+		t.Fatalf("WriteFile() returned error: %v", err)
+	}
+
+	config = getDefaultConfig()
+	config.Paths.Proc = procRoot
+	config.Paths.Sys = t.TempDir()
+	config.Warmup.Enabled = false
+
+	cacheMutex.Lock()
+	metricCache = make(map[string]MetricStatus)
+	cacheMutex.Unlock()
+
+	interfaceCacheMutex.Lock()
+	interfaceCache = make(map[string]interfaceSnapshot)
+	interfaceCacheMutex.Unlock()
+
+	samples, _ := (&networkCollector{}).Collect(context.Background())
+	publishSamples(samples)
+
+	// eth0 has no limits, so its unknown rates do not fail the probe
+	metrics := snapshotMetrics()
+	if metric := metrics["network_eth0_rx_bytes"]; metric.Status != "UNKNOWN" {
+		t.Errorf("network_eth0_rx_bytes status = %v, want UNKNOWN", metric.Status)
+	}
+	if status := overallStatus(metrics); status != "OK" {
+		t.Errorf("overallStatus() = %v, want OK", status)
+	}
+}
+
+func TestCollectNetworkUnreadableSockets(t *testing.T) {
+	oldConfig := config
+	defer func() { config = oldConfig }()
+
+	config = Config{startTime: time.Now()}
+	config.Paths.Proc = filepath.Join(t.TempDir(), "missing")
+	config.Thresholds.MaxConnections = 1000
+	config.Thresholds.MaxTCPStates = map[string]float64{"close_wait": 10}
+
+	cacheMutex.Lock()
+	metricCache = make(map[string]MetricStatus)
+	cacheMutex.Unlock()
+
+	samples, _ := (&networkCollector{}).Collect(context.Background())
+	publishSamples(samples)
+
+	// The socket counts are unknown, not a healthy 0
+	metrics := snapshotMetrics()
+	for _, name := range []string{"network_connections", "network_tcp_close_wait", "network_tcp_established"} {
+		if metric := metrics[name]; metric.Status != "UNKNOWN" || metric.Error == "" {
+			t.Errorf("%s = %+v, want UNKNOWN with an error", name, metric)
+		}
+	}
+	if status := overallStatus(metrics); status != "KO" {
+		t.Errorf("overallStatus() = %v, want KO", status)
 	}
 }
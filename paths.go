@@ -0,0 +1,35 @@
+package main
+
+import (
+	"path/filepath"
+)
+
+// Default mount points of procfs and sysfs
+const (
+	defaultProcRoot = "/proc"
+	defaultSysRoot  = "/sys"
+)
+
+// procPath joins elem to the configured procfs root, e.g. procPath("net", "dev")
+func procPath(elem ...string) string {
+	configMutex.RLock()
+	root := config.Paths.Proc
+	configMutex.RUnlock()
+
+	if root == "" {
+		root = defaultProcRoot
+	}
+	return filepath.Join(append([]string{root}, elem...)...)
+}
+
+// sysPath joins elem to the configured sysfs root, e.g. sysPath("class", "net")
+func sysPath(elem ...string) string {
+	configMutex.RLock()
+	root := config.Paths.Sys
+	configMutex.RUnlock()
+
+	if root == "" {
+		root = defaultSysRoot
+	}
+	return filepath.Join(append([]string{root}, elem...)...)
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+// useFixture points the proc and sys roots of the active configuration at a
+// snapshot in testdata. Callers restore the configuration themselves.
+func useFixture(name string) {
+	config.Paths.Proc = filepath.Join("testdata", name, "proc")
+	config.Paths.Sys = filepath.Join("testdata", name, "sys")
+}
+
+func TestProcAndSysPath(t *testing.T) {
+	oldConfig := config
+	defer func() { config = oldConfig }()
+
+	tests := []struct {
+		name     string
+		proc     string
+		sys      string
+		wantProc string
+		wantSys  string
+	}{
+		{
+			name:     "unset roots use the standard mount points",
+			wantProc: "/proc/net/dev",
+			wantSys:  "/sys/class/net/eth0/speed",
+		},
+		{
+			name:     "host roots bind-mounted in a container",
+			proc:     "/host/proc",
+			sys:      "/host/sys/",
+			wantProc: "/host/proc/net/dev",
+			wantSys:  "/host/sys/class/net/eth0/speed",
+		},
+		{
+			name:     "relative fixture roots",
+			proc:     "testdata/idle/proc",
+			sys:      "testdata/idle/sys",
+			wantProc: "testdata/idle/proc/net/dev",
+			wantSys:  "testdata/idle/sys/class/net/eth0/speed",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config.Paths.Proc = tt.proc
+			config.Paths.Sys = tt.sys
+
+			if got := procPath("net", "dev"); got != tt.wantProc {
+				t.Errorf("procPath() = %v, want %v", got, tt.wantProc)
+			}
+			if got := sysPath("class", "net", "eth0", "speed"); got != tt.wantSys {
+				t.Errorf("sysPath() = %v, want %v", got, tt.wantSys)
+			}
+		})
+	}
+}
@@ -0,0 +1,308 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// userHZ is the unit of the CPU times in /proc/<pid>/stat
+const userHZ = 100
+
+// processStat holds the fields of /proc/<pid>/stat used by the probe
+type processStat struct {
+	cpuTicks  uint64 // utime + stime, in userHZ ticks
+	startTime uint64 // Start time after boot, in userHZ ticks
+	rssPages  uint64 // Resident set size in pages
+}
+
+// processInstance identifies a process across PID reuse
+type processInstance struct {
+	pid       int
+	startTime uint64
+}
+
+// processSnapshot stores the previous instances of a monitored process for delta calculation
+type processSnapshot struct {
+	cpuTicks  map[processInstance]uint64
+	timestamp time.Time
+}
+
+var (
+	processCache      = make(map[string]processSnapshot) // Keyed by monitored process name
+	processCacheMutex sync.Mutex
+)
+
+// parseProcessStat parses the content of /proc/<pid>/stat.
+// Format: pid (comm) state ppid ... utime stime ... starttime vsize rss ...
+// The command name may contain spaces and parentheses, so fields are counted
+// from the last closing parenthesis.
+func parseProcessStat(data string) (processStat, error) {
+	end := strings.LastIndexByte(data, ')')
+	if end < 0 {
+		return processStat{}, fmt.Errorf("missing command name")
+	}
+
+	// fields[0] is the state, the third field of the file
+	fields := strings.Fields(data[end+1:])
+	if len(fields) < 22 {
+		return processStat{}, fmt.Errorf("expected at least 24 fields, got %d", len(fields)+2)
+	}
+
+	values := make(map[int]uint64)
+	for _, index := range []int{11, 12, 19, 21} {
+		value, err := strconv.ParseUint(fields[index], 10, 64)
+		if err != nil {
+			return processStat{}, fmt.Errorf("field %d: %w", index+3, err)
+		}
+		values[index] = value
+	}
+
+	return processStat{
+		cpuTicks:  values[11] + values[12],
+		startTime: values[19],
+		rssPages:  values[21],
+	}, nil
+}
+
+// scanProcesses returns the PIDs in /proc accepted by match.
+// Processes exiting while scanning are skipped by match returning false.
+func scanProcesses(match func(pid int) bool) ([]int, error) {
+	entries, err := os.ReadDir(procPath())
+	if err != nil {
+		return nil, err
+	}
+
+	var pids []int
+	for _, entry := range entries {
+		pid, err := strconv.Atoi(entry.Name())
+		if err != nil {
+			continue
+		}
+		if match(pid) {
+			pids = append(pids, pid)
+		}
+	}
+
+	return pids, nil
+}
+
+// findProcesses returns the PIDs of a monitored process, matched by pidfile,
+// command line regular expression or process name, in that order of precedence
+func findProcesses(process ProcessMonitor) ([]int, error) {
+	if process.PIDFile != "" {
+		data, err := os.ReadFile(process.PIDFile)
+		if errors.Is(err, os.ErrNotExist) {
+			// Not running
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+		if err != nil {
+			return nil, fmt.Errorf("%s: invalid PID: %w", process.PIDFile, err)
+		}
+
+		// A stale pidfile points to a process that no longer exists
+		if _, err := os.Stat(procPath(strconv.Itoa(pid))); err != nil {
+			return nil, nil
+		}
+		return []int{pid}, nil
+	}
+
+	if process.Cmdline != "" {
+		pattern, err := regexp.Compile(process.Cmdline)
+		if err != nil {
+			return nil, err
+		}
+
+		probePID := ownPID()
+		return scanProcesses(func(pid int) bool {
+			if pid == probePID {
+				// The probe's own command line may contain the pattern
+				return false
+			}
+			cmdline, err := os.ReadFile(procPath(strconv.Itoa(pid), "cmdline"))
+			if err != nil || len(cmdline) == 0 {
+				// Kernel threads have an empty command line
+				return false
+			}
+			return pattern.Match(formatCmdline(cmdline))
+		})
+	}
+
+	return findProcessesByName(process.Name)
+}
+
+// ownPID returns the PID of the probe as seen in the procfs root, which differs
+// from os.Getpid when a host's /proc is mounted in the probe's container
+func ownPID() int {
+	if target, err := os.Readlink(procPath("self")); err == nil {
+		if pid, err := strconv.Atoi(target); err == nil {
+			return pid
+		}
+	}
+	return os.Getpid()
+}
+
+// formatCmdline joins the NUL-separated arguments of /proc/<pid>/cmdline with spaces
+func formatCmdline(data []byte) []byte {
+	return bytes.TrimSpace(bytes.ReplaceAll(data, []byte{0}, []byte{' '}))
+}
+
+// processUsage holds the aggregated state of the instances of a monitored process
+type processUsage struct {
+	Count    float64 // Running instances
+	RSS      float64 // Total resident memory in MB
+	CPU      float64 // Total CPU percentage since the previous reading, 100 per busy core
+	Restarts float64 // Instances replaced since the previous reading
+}
+
+// getProcessUsage reads /proc/<pid>/stat for every PID and aggregates the
+// usage of a monitored process. The first reading reports no CPU or restarts.
+func getProcessUsage(name string, pids []int) (processUsage, error) {
+	var usage processUsage
+	var errs []error
+	currentTime := time.Now()
+	current := make(map[processInstance]uint64)
+
+	for _, pid := range pids {
+		data, err := os.ReadFile(procPath(strconv.Itoa(pid), "stat"))
+		if errors.Is(err, os.ErrNotExist) {
+			// Exited since it was found
+			continue
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%d: %w", pid, err))
+			continue
+		}
+		stat, err := parseProcessStat(string(data))
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%d: %w", pid, err))
+			continue
+		}
+
+		current[processInstance{pid: pid, startTime: stat.startTime}] = stat.cpuTicks
+		usage.Count++
+		usage.RSS += float64(stat.rssPages) * float64(os.Getpagesize()) / (1024 * 1024)
+	}
+
+	processCacheMutex.Lock()
+	previous, exists := processCache[name]
+	processCache[name] = processSnapshot{cpuTicks: current, timestamp: currentTime}
+	processCacheMutex.Unlock()
+
+	if !exists {
+		return usage, errors.Join(errs...)
+	}
+
+	elapsed := currentTime.Sub(previous.timestamp).Seconds()
+	started, stopped := 0.0, 0.0
+	ticks := uint64(0)
+	for instance, cpuTicks := range current {
+		previousTicks, seen := previous.cpuTicks[instance]
+		if !seen {
+			started++
+			continue
+		}
+		if cpuTicks >= previousTicks {
+			ticks += cpuTicks - previousTicks
+		}
+	}
+	for instance := range previous.cpuTicks {
+		if _, running := current[instance]; !running {
+			stopped++
+		}
+	}
+
+	if elapsed > 0 {
+		usage.CPU = float64(ticks) / userHZ / elapsed * 100.0
+	}
+
+	// An instance that stopped and another that started is a restart,
+	// while new instances alone are scaling up
+	usage.Restarts = min(started, stopped)
+
+	return usage, errors.Join(errs...)
+}
+
+// pruneProcessCache removes cached processes that are no longer monitored
+func pruneProcessCache(processes []ProcessMonitor) {
+	monitored := make(map[string]bool, len(processes))
+	for _, process := range processes {
+		monitored[process.Name] = true
+	}
+
+	processCacheMutex.Lock()
+	defer processCacheMutex.Unlock()
+
+	for name := range processCache {
+		if !monitored[name] {
+			delete(processCache, name)
+		}
+	}
+}
+
+// processCollector reports liveness and resource usage of config.Monitoring.Processes
+type processCollector struct{}
+
+// Name identifies the collector in logs
+func (c *processCollector) Name() string {
+	return "process"
+}
+
+// Interval is the delay between two process collections
+func (c *processCollector) Interval() time.Duration {
+	return collectorInterval(c.Name(), 2*time.Second)
+}
+
+// Collect returns count, RSS, CPU and restart samples per monitored process.
+// The count is KO below min_count (1 by default); RSS and CPU are checked
+// against max_rss and max_cpu when set.
+func (c *processCollector) Collect(ctx context.Context) ([]Sample, error) {
+	cfg := currentConfig()
+	var samples []Sample
+	var errs []error
+
+	for _, process := range cfg.Monitoring.Processes {
+		pids, findErr := findProcesses(process)
+		if findErr != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", process.Name, findErr))
+		}
+
+		usage, err := getProcessUsage(process.Name, pids)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", process.Name, err))
+		}
+
+		minCount := float64(process.MinCount)
+		if minCount <= 0 {
+			minCount = 1
+		}
+
+		labels := map[string]string{"process": process.Name}
+		processSamples := []Sample{
+			{Name: "process_" + process.Name + "_count", Value: usage.Count, NoMax: true, Min: minCount, Metric: "process_count", Labels: labels},
+			{Name: "process_" + process.Name + "_rss", Value: usage.RSS, Max: process.MaxRSS, NoMax: process.MaxRSS <= 0, Metric: "process_rss", Labels: labels},
+			{Name: "process_" + process.Name + "_cpu", Value: usage.CPU, Max: process.MaxCPU, NoMax: process.MaxCPU <= 0, Metric: "process_cpu", Labels: labels},
+			{Name: "process_" + process.Name + "_restarts", Value: usage.Restarts, NoMax: true, Metric: "process_restarts", Labels: labels},
+		}
+		// An unreadable pidfile or /proc says nothing about the process
+		if findErr != nil {
+			failSamples(processSamples, findErr)
+		}
+		samples = append(samples, processSamples...)
+	}
+
+	pruneProcessCache(cfg.Monitoring.Processes)
+
+	return samples, errors.Join(errs...)
+}
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestParseProcessStat(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		want    processStat
+		wantErr bool
+	}{
+		{
+			name: "plain command",
+			data: "1234 (nginx) S 1 1234 1234 0 -1 4194560 1500 0 0 0 250 50 0 0 20 0 1 0 98765 104857600 2560 18446744073709551615 1 1 0 0 0 0 0 0 0 0 0 0 17 3 0 0 0 0 0",
+			want: processStat{cpuTicks: 300, startTime: 98765, rssPages: 2560},
+		},
+		{
+			name: "command with spaces and parentheses",
+			data: "42 (my (odd) proc) R 1 42 42 0 -1 0 0 0 0 0 10 5 0 0 20 0 1 0 500 0 100 0",
+			want: processStat{cpuTicks: 15, startTime: 500, rssPages: 100},
+		},
+		{
+			name:    "truncated",
+			data:    "42 (proc) R 1 42",
+			wantErr: true,
+		},
+		{
+			name:    "missing command",
+			data:    "42 proc R 1 42",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseProcessStat(tt.data)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseProcessStat() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("parseProcessStat() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFormatCmdline(t *testing.T) {
+	got := string(formatCmdline([]byte("nginx: master process\x00-c\x00/etc/nginx/nginx.conf\x00")))
+	want := "nginx: master process -c /etc/nginx/nginx.conf"
+	if got != want {
+		t.Errorf("formatCmdline() = %q, want %q", got, want)
+	}
+}
+
+func TestGetProcessUsageDetectsRestarts(t *testing.T) {
+	pid := os.Getpid()
+
+	// A previous instance that has since exited
+	processCacheMutex.Lock()
+	processCache = map[string]processSnapshot{
+		"test": {
+			cpuTicks:  map[processInstance]uint64{{pid: 1 << 30, startTime: 1}: 100},
+			timestamp: time.Now().Add(-time.Second),
+		},
+	}
+	processCacheMutex.Unlock()
+
+	usage, err := getProcessUsage("test", []int{pid})
+	if err != nil {
+		t.Fatalf("getProcessUsage() returned error: %v", err)
+	}
+	if usage.Count != 1 || usage.RSS <= 0 || usage.Restarts != 1 {
+		t.Errorf("getProcessUsage() = %+v, want one running instance replacing the previous one", usage)
+	}
+
+	// Same instance again: no restart
+	usage, err = getProcessUsage("test", []int{pid})
+	if err != nil {
+		t.Fatalf("getProcessUsage() returned error: %v", err)
+	}
+	if usage.Restarts != 0 || usage.CPU < 0 {
+		t.Errorf("getProcessUsage() = %+v, want no restart", usage)
+	}
+
+	pruneProcessCache(nil)
+}
+
+func TestCollectProcessMetrics(t *testing.T) {
+	pidFile := filepath.Join(t.TempDir(), "probe.pid")
+	if err := os.WriteFile(pidFile, []byte(strconv.Itoa(os.Getpid())+"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	oldConfig := config
+	config = Config{
+		startTime: time.Now(),
+	}
+	config.Monitoring.Processes = []ProcessMonitor{
+		{Name: "self", PIDFile: pidFile, MaxRSS: 1 << 20},
+		{Name: "missing", PIDFile: filepath.Join(t.TempDir(), "missing.pid")},
+		{Name: "workers", Cmdline: "^no-such-command ", MinCount: 4},
+	}
+	defer func() { config = oldConfig }()
+
+	cacheMutex.Lock()
+	metricCache = make(map[string]MetricStatus)
+	cacheMutex.Unlock()
+
+	samples, err := (&processCollector{}).Collect(context.Background())
+	if err != nil {
+		t.Fatalf("processCollector.Collect() returned error: %v", err)
+	}
+	publishSamples(samples)
+
+	cacheMutex.RLock()
+	defer cacheMutex.RUnlock()
+
+	tests := []struct {
+		key        string
+		wantMin    float64
+		wantStatus string
+	}{
+		{key: "process_self_count", wantMin: 1, wantStatus: "OK"},
+		{key: "process_missing_count", wantMin: 1, wantStatus: "KO"},
+		{key: "process_workers_count", wantMin: 4, wantStatus: "KO"},
+		{key: "process_self_rss", wantStatus: "OK"},
+	}
+	for _, tt := range tests {
+		metric, exists := metricCache[tt.key]
+		if !exists {
+			t.Errorf("%s not found in cache", tt.key)
+			continue
+		}
+		if metric.Min != tt.wantMin || metric.Status != tt.wantStatus {
+			t.Errorf("%s = %+v, want min %v and status %s", tt.key, metric, tt.wantMin, tt.wantStatus)
+		}
+	}
+}
+
+func TestFindProcessesFixture(t *testing.T) {
+	oldConfig := config
+	defer func() { config = oldConfig }()
+
+	useFixture("idle")
+
+	tests := []struct {
+		name    string
+		process ProcessMonitor
+		want    []int
+	}{
+		{name: "by name", process: ProcessMonitor{Name: "nginx"}, want: []int{4242}},
+		{name: "by command line", process: ProcessMonitor{Name: "web", Cmdline: "^nginx: master .* -g daemon off;$"}, want: []int{4242}},
+		{name: "not running", process: ProcessMonitor{Name: "haproxy"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pids, err := findProcesses(tt.process)
+			if err != nil {
+				t.Fatalf("findProcesses() returned error: %v", err)
+			}
+			if !slices.Equal(pids, tt.want) {
+				t.Errorf("findProcesses() = %v, want %v", pids, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetProcessUsageFixtures(t *testing.T) {
+	oldConfig := config
+	defer func() { config = oldConfig }()
+	defer pruneProcessCache(nil)
+
+	useFixture("idle")
+	usage, err := getProcessUsage("nginx", []int{4242})
+	if err != nil {
+		t.Fatalf("getProcessUsage() returned error: %v", err)
+	}
+	wantRSS := 2560 * float64(os.Getpagesize()) / (1024 * 1024)
+	if usage.Count != 1 || usage.RSS != wantRSS || usage.CPU != 0 {
+		t.Errorf("getProcessUsage() = %+v, want one instance using %v MB and no CPU on first reading", usage, wantRSS)
+	}
+
+	// Same instance, 300 more ticks of CPU time and ten times the memory
+	useFixture("busy")
+	usage, err = getProcessUsage("nginx", []int{4242})
+	if err != nil {
+		t.Fatalf("getProcessUsage() returned error: %v", err)
+	}
+	if usage.RSS != 10*wantRSS || usage.CPU <= 0 || usage.Restarts != 0 {
+		t.Errorf("getProcessUsage() = %+v, want %v MB, CPU usage and no restart", usage, 10*wantRSS)
+	}
+}
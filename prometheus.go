@@ -0,0 +1,108 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// prometheusHandler handles the /metrics endpoint in Prometheus text exposition format
+func prometheusHandler(w http.ResponseWriter, r *http.Request) {
+	metrics := snapshotMetrics()
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	writePrometheusMetrics(w, metrics, currentWarmupFactor(), currentAdminState())
+}
+
+// writePrometheusMetrics writes every cached metric as current, max and status gauges
+func writePrometheusMetrics(w io.Writer, metrics map[string]MetricStatus, warmupFactor float64, admin *AdminState) {
+	// Sort keys for a stable output
+	keys := make([]string, 0, len(metrics))
+	for key := range metrics {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	writePrometheusFamily(w, "probe_metric_current", "Current value of each probe metric.",
+		keys, metrics, func(m MetricStatus) float64 { return m.Current })
+	writePrometheusFamily(w, "probe_metric_max", "Effective maximum of each probe metric, including warmup (0 when unbounded).",
+		keys, metrics, func(m MetricStatus) float64 { return m.Max })
+	writePrometheusFamily(w, "probe_metric_min", "Minimum of each probe metric (0 when unbounded).",
+		keys, metrics, func(m MetricStatus) float64 { return m.Min })
+	writePrometheusFamily(w, "probe_metric_ok", "1 when the probe metric does not make the probe KO, 0 otherwise.",
+		keys, metrics, func(m MetricStatus) float64 { return boolToFloat(!metricFailing(m)) })
+
+	fmt.Fprintf(w, "# HELP probe_warmup_factor Factor applied to every maximum during warmup.\n")
+	fmt.Fprintf(w, "# TYPE probe_warmup_factor gauge\n")
+	fmt.Fprintf(w, "probe_warmup_factor %s\n", formatPrometheusValue(warmupFactor))
+
+	fmt.Fprintf(w, "# HELP probe_admin_mode 1 for the active operator mode, 0 otherwise.\n")
+	fmt.Fprintf(w, "# TYPE probe_admin_mode gauge\n")
+	for _, mode := range []string{adminModeDrain, adminModeMaintenance, adminModeShutdown} {
+		fmt.Fprintf(w, "probe_admin_mode{mode=\"%s\"} %s\n", mode, formatPrometheusValue(boolToFloat(admin != nil && admin.Mode == mode)))
+	}
+
+	fmt.Fprintf(w, "# HELP probe_status_ok 1 when the overall probe status is OK, 0 when KO.\n")
+	fmt.Fprintf(w, "# TYPE probe_status_ok gauge\n")
+	fmt.Fprintf(w, "probe_status_ok %s\n", formatPrometheusValue(boolToFloat(admin == nil && overallStatus(metrics) == "OK")))
+}
+
+// writePrometheusFamily writes one gauge family with a line per metric
+func writePrometheusFamily(w io.Writer, name, help string, keys []string,
+	metrics map[string]MetricStatus, value func(MetricStatus) float64) {
+	fmt.Fprintf(w, "# HELP %s %s\n", name, help)
+	fmt.Fprintf(w, "# TYPE %s gauge\n", name)
+	for _, key := range keys {
+		metric := metrics[key]
+		fmt.Fprintf(w, "%s%s %s\n", name, formatPrometheusLabels(key, metric), formatPrometheusValue(value(metric)))
+	}
+}
+
+// formatPrometheusLabels builds the label set of a metric.
+// The metric family is exposed as the "metric" label, followed by its own labels sorted by name.
+func formatPrometheusLabels(key string, metric MetricStatus) string {
+	family := metric.metric
+	if family == "" {
+		family = key
+	}
+
+	names := make([]string, 0, len(metric.labels))
+	for name := range metric.labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	b.WriteString(`{metric="`)
+	b.WriteString(escapePrometheusLabel(family))
+	b.WriteString(`"`)
+	for _, name := range names {
+		fmt.Fprintf(&b, `,%s="%s"`, name, escapePrometheusLabel(metric.labels[name]))
+	}
+	b.WriteString("}")
+
+	return b.String()
+}
+
+// escapePrometheusLabel escapes a label value for the text exposition format
+func escapePrometheusLabel(value string) string {
+	value = strings.ReplaceAll(value, `\`, `\\`)
+	value = strings.ReplaceAll(value, "\n", `\n`)
+	return strings.ReplaceAll(value, `"`, `\"`)
+}
+
+// formatPrometheusValue formats a sample value with the shortest exact representation
+func formatPrometheusValue(value float64) string {
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+// boolToFloat converts a boolean to a 0/1 gauge value
+func boolToFloat(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
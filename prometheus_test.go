@@ -0,0 +1,109 @@
+package main
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestWritePrometheusMetrics(t *testing.T) {
+	metrics := map[string]MetricStatus{
+		"cpu_usage": {
+			Current: 45.5,
+			Max:     80,
+			Status:  "OK",
+		},
+		"disk_var_log": {
+			Current: 97,
+			Max:     95,
+			Status:  "KO",
+			metric:  "disk",
+			labels:  map[string]string{"path": "/var/log"},
+		},
+	}
+
+	var b strings.Builder
+	writePrometheusMetrics(&b, metrics, 0.5, &AdminState{Mode: adminModeDrain})
+	output := b.String()
+
+	wantLines := []string{
+		"# TYPE probe_metric_current gauge",
+		`probe_metric_current{metric="cpu_usage"} 45.5`,
+		`probe_metric_current{metric="disk",path="/var/log"} 97`,
+		`probe_metric_max{metric="cpu_usage"} 80`,
+		`probe_metric_max{metric="disk",path="/var/log"} 95`,
+		`probe_metric_ok{metric="cpu_usage"} 1`,
+		`probe_metric_ok{metric="disk",path="/var/log"} 0`,
+		"probe_warmup_factor 0.5",
+		`probe_admin_mode{mode="drain"} 1`,
+		`probe_admin_mode{mode="maintenance"} 0`,
+		"probe_status_ok 0",
+	}
+
+	for _, line := range wantLines {
+		if !strings.Contains(output, line+"\n") {
+			t.Errorf("output missing line %q\n%s", line, output)
+		}
+	}
+}
+
+func TestFormatPrometheusLabels(t *testing.T) {
+	tests := []struct {
+		name   string
+		key    string
+		metric MetricStatus
+		want   string
+	}{
+		{
+			name:   "no family",
+			key:    "memory",
+			metric: MetricStatus{},
+			want:   `{metric="memory"}`,
+		},
+		{
+			name: "sorted labels",
+			key:  "test",
+			metric: MetricStatus{
+				metric: "family",
+				labels: map[string]string{"b": "2", "a": "1"},
+			},
+			want: `{metric="family",a="1",b="2"}`,
+		},
+		{
+			name: "escaped value",
+			key:  "test",
+			metric: MetricStatus{
+				metric: "family",
+				labels: map[string]string{"path": `/a"b\c`},
+			},
+			want: `{metric="family",path="/a\"b\\c"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := formatPrometheusLabels(tt.key, tt.metric)
+			if got != tt.want {
+				t.Errorf("formatPrometheusLabels() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPrometheusHandler(t *testing.T) {
+	cacheMutex.Lock()
+	metricCache = map[string]MetricStatus{
+		"memory": {Current: 10, Max: 90, Status: "OK"},
+	}
+	cacheMutex.Unlock()
+
+	recorder := httptest.NewRecorder()
+	prometheusHandler(recorder, httptest.NewRequest("GET", "/metrics", nil))
+
+	if contentType := recorder.Header().Get("Content-Type"); !strings.HasPrefix(contentType, "text/plain") {
+		t.Errorf("Content-Type = %v, want text/plain", contentType)
+	}
+	if !strings.Contains(recorder.Body.String(), `probe_metric_current{metric="memory"} 10`) {
+		t.Errorf("body missing memory metric:\n%s", recorder.Body.String())
+	}
+}
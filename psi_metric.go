@@ -0,0 +1,186 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// psiLine holds one line of a /proc/pressure file
+type psiLine struct {
+	Avg10  float64 // Percentage of time stalled over the last 10 seconds
+	Avg60  float64 // Percentage of time stalled over the last 60 seconds
+	Avg300 float64 // Percentage of time stalled over the last 300 seconds
+	Total  uint64  // Cumulative stall time in microseconds
+}
+
+// psiSnapshot stores the previous stall total for delta calculation
+type psiSnapshot struct {
+	total     uint64
+	timestamp time.Time
+}
+
+var (
+	psiCache      = make(map[string]psiSnapshot)
+	psiCacheMutex sync.Mutex
+)
+
+// psiResources lists the pressure files read by the collector
+var psiResources = []string{"cpu", "memory", "io"}
+
+// parsePSI parses the content of a /proc/pressure file.
+// Format: some avg10=0.00 avg60=0.00 avg300=0.00 total=0
+func parsePSI(data string) (map[string]psiLine, error) {
+	lines := make(map[string]psiLine)
+
+	for _, line := range strings.Split(strings.TrimSpace(data), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+
+		var parsed psiLine
+		for _, field := range fields[1:] {
+			key, value, found := strings.Cut(field, "=")
+			if !found {
+				return nil, fmt.Errorf("invalid field %q", field)
+			}
+
+			var err error
+			switch key {
+			case "avg10":
+				parsed.Avg10, err = strconv.ParseFloat(value, 64)
+			case "avg60":
+				parsed.Avg60, err = strconv.ParseFloat(value, 64)
+			case "avg300":
+				parsed.Avg300, err = strconv.ParseFloat(value, 64)
+			case "total":
+				parsed.Total, err = strconv.ParseUint(value, 10, 64)
+			}
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s value: %w", key, err)
+			}
+		}
+
+		lines[fields[0]] = parsed
+	}
+
+	if len(lines) == 0 {
+		return nil, fmt.Errorf("no pressure lines found")
+	}
+
+	return lines, nil
+}
+
+// getPSIStallRate returns the percentage of time stalled since the previous reading.
+// ok is false on the first reading and when the total went backwards.
+func getPSIStallRate(key string, total uint64) (float64, bool) {
+	currentTime := time.Now()
+
+	psiCacheMutex.Lock()
+	defer psiCacheMutex.Unlock()
+
+	snapshot, exists := psiCache[key]
+	psiCache[key] = psiSnapshot{
+		total:     total,
+		timestamp: currentTime,
+	}
+
+	if !exists {
+		// First reading, no rate yet
+		return 0, false
+	}
+
+	// Stall totals are in microseconds
+	deltas := counterDeltas{elapsed: float64(currentTime.Sub(snapshot.timestamp).Microseconds())}
+	rate := deltas.rate(snapshot.total, total) * 100.0
+	return rate, !deltas.stale()
+}
+
+// psiCollector reports pressure stall information for CPU, memory and IO
+type psiCollector struct{}
+
+// Name identifies the collector in logs
+func (c *psiCollector) Name() string {
+	return "psi"
+}
+
+// Interval is the delay between two PSI collections
+func (c *psiCollector) Interval() time.Duration {
+	return collectorInterval(c.Name(), 2*time.Second)
+}
+
+// Collect returns avg10, avg60, avg300 and stall rate samples for every
+// resource and kind. avg10 is checked against the matching max_psi threshold.
+// Resources without a pressure file are reported as unsupported.
+func (c *psiCollector) Collect(ctx context.Context) ([]Sample, error) {
+	cfg := currentConfig()
+	thresholds := map[string]float64{
+		"cpu_some":    cfg.Thresholds.MaxPSICPUSome,
+		"cpu_full":    cfg.Thresholds.MaxPSICPUFull,
+		"memory_some": cfg.Thresholds.MaxPSIMemorySome,
+		"memory_full": cfg.Thresholds.MaxPSIMemoryFull,
+		"io_some":     cfg.Thresholds.MaxPSIIOSome,
+		"io_full":     cfg.Thresholds.MaxPSIIOFull,
+	}
+
+	var samples []Sample
+	var errs []error
+
+	for _, resource := range psiResources {
+		data, err := os.ReadFile(procPath("pressure", resource))
+		if errors.Is(err, os.ErrNotExist) {
+			// Kernel without PSI support
+			samples = append(samples, Sample{
+				Name:        "psi_" + resource,
+				Unsupported: true,
+				Metric:      "psi",
+				Labels:      map[string]string{"resource": resource},
+			})
+			continue
+		}
+		var lines map[string]psiLine
+		if err == nil {
+			lines, err = parsePSI(string(data))
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", resource, err))
+			// Without a limit on the resource, its pressure is only informational
+			samples = append(samples, Sample{
+				Name:   "psi_" + resource,
+				Err:    err,
+				NoMax:  thresholds[resource+"_some"] <= 0 && thresholds[resource+"_full"] <= 0,
+				Metric: "psi",
+				Labels: map[string]string{"resource": resource},
+			})
+			continue
+		}
+
+		for _, kind := range []string{"some", "full"} {
+			line, exists := lines[kind]
+			if !exists {
+				// Older kernels have no "full" line for cpu
+				continue
+			}
+
+			key := resource + "_" + kind
+			labels := map[string]string{"resource": resource, "kind": kind}
+			maxAvg10 := thresholds[key]
+			stall, ok := getPSIStallRate(key, line.Total)
+
+			samples = append(samples,
+				Sample{Name: "psi_" + key + "_avg10", Value: line.Avg10, Max: maxAvg10, NoMax: maxAvg10 <= 0, Metric: "psi_avg10", Labels: labels},
+				Sample{Name: "psi_" + key + "_avg60", Value: line.Avg60, NoMax: true, Metric: "psi_avg60", Labels: labels},
+				Sample{Name: "psi_" + key + "_avg300", Value: line.Avg300, NoMax: true, Metric: "psi_avg300", Labels: labels},
+				Sample{Name: "psi_" + key + "_stall", Value: stall, NoMax: true, Stale: !ok, Metric: "psi_stall", Labels: labels},
+			)
+		}
+	}
+
+	return samples, errors.Join(errs...)
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParsePSI(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		want    map[string]psiLine
+		wantErr bool
+	}{
+		{
+			name: "some and full",
+			data: "some avg10=1.15 avg60=1.58 avg300=1.66 total=29704726\nfull avg10=0.50 avg60=0.25 avg300=0.10 total=1755547\n",
+			want: map[string]psiLine{
+				"some": {Avg10: 1.15, Avg60: 1.58, Avg300: 1.66, Total: 29704726},
+				"full": {Avg10: 0.50, Avg60: 0.25, Avg300: 0.10, Total: 1755547},
+			},
+		},
+		{
+			name: "cpu on older kernels",
+			data: "some avg10=0.00 avg60=0.00 avg300=0.00 total=0\n",
+			want: map[string]psiLine{
+				"some": {},
+			},
+		},
+		{
+			name:    "invalid value",
+			data:    "some avg10=abc avg60=0.00 avg300=0.00 total=0\n",
+			wantErr: true,
+		},
+		{
+			name:    "empty file",
+			data:    "",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parsePSI(tt.data)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parsePSI() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("parsePSI() = %+v, want %+v", got, tt.want)
+			}
+			for kind, want := range tt.want {
+				if got[kind] != want {
+					t.Errorf("parsePSI()[%s] = %+v, want %+v", kind, got[kind], want)
+				}
+			}
+		})
+	}
+}
+
+func TestGetPSIStallRate(t *testing.T) {
+	psiCacheMutex.Lock()
+	psiCache = make(map[string]psiSnapshot)
+	psiCacheMutex.Unlock()
+
+	if rate, ok := getPSIStallRate("test_some", 1000); ok {
+		t.Errorf("getPSIStallRate() first call = %v, want no rate", rate)
+	}
+
+	// Pretend the previous reading happened one second ago
+	psiCacheMutex.Lock()
+	psiCache["test_some"] = psiSnapshot{total: 1000, timestamp: time.Now().Add(-time.Second)}
+	psiCacheMutex.Unlock()
+
+	// 250ms stalled over one second
+	rate, ok := getPSIStallRate("test_some", 251000)
+	if !ok || rate < 24.9 || rate > 25.1 {
+		t.Errorf("getPSIStallRate() = %v (ok %v), want about 25", rate, ok)
+	}
+
+	// A lower total is a reset, not a negative stall
+	if rate, ok := getPSIStallRate("test_some", 500); ok {
+		t.Errorf("getPSIStallRate() after a reset = %v, want no rate", rate)
+	}
+}
+
+func TestPSICollector(t *testing.T) {
+	oldConfig := config
+	config = getDefaultConfig()
+	config.Thresholds.MaxPSIMemoryFull = 10.0
+	defer func() { config = oldConfig }()
+
+	samples, err := (&psiCollector{}).Collect(context.Background())
+	if err != nil {
+		t.Fatalf("psiCollector.Collect() returned error: %v", err)
+	}
+
+	for _, sample := range samples {
+		if sample.Unsupported {
+			// Kernel without PSI, nothing else to check for this resource
+			continue
+		}
+		if sample.Value < 0 {
+			t.Errorf("%s = %v, want non-negative value", sample.Name, sample.Value)
+		}
+		if sample.Name == "psi_memory_full_avg10" && (sample.NoMax || sample.Max != 10.0) {
+			t.Errorf("psi_memory_full_avg10 max = %v (NoMax %v), want 10", sample.Max, sample.NoMax)
+		}
+		if strings.HasSuffix(sample.Name, "_avg60") && !sample.NoMax {
+			t.Errorf("%s has a threshold, want informational", sample.Name)
+		}
+	}
+}
+
+func TestPSICollectorFixtures(t *testing.T) {
+	oldConfig := config
+	config = Config{
+		startTime: time.Now(),
+	}
+	config.Thresholds.MaxPSICPUSome = 40.0
+	defer func() { config = oldConfig }()
+
+	useFixture("busy")
+	samples, err := (&psiCollector{}).Collect(context.Background())
+	if err != nil {
+		t.Fatalf("psiCollector.Collect() returned error: %v", err)
+	}
+
+	values := make(map[string]Sample)
+	for _, sample := range samples {
+		values[sample.Name] = sample
+	}
+	if cpu := values["psi_cpu_some_avg10"]; cpu.Value != 45 || cpu.Max != 40 {
+		t.Errorf("psi_cpu_some_avg10 = %+v, want 45 with max 40", cpu)
+	}
+	if len(samples) != 3*2*4 {
+		t.Errorf("psiCollector.Collect() returned %d samples, want 24", len(samples))
+	}
+
+	// Kernels before 4.20 have no /proc/pressure
+	useFixture("legacy")
+	samples, err = (&psiCollector{}).Collect(context.Background())
+	if err != nil {
+		t.Fatalf("psiCollector.Collect() returned error: %v", err)
+	}
+	if len(samples) != len(psiResources) {
+		t.Fatalf("psiCollector.Collect() returned %d samples, want one per resource", len(samples))
+	}
+	for _, sample := range samples {
+		if !sample.Unsupported {
+			t.Errorf("%s = %+v, want unsupported", sample.Name, sample)
+		}
+	}
+}
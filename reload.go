@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// reloadConfig re-reads the configuration file and swaps the active configuration.
+// The active configuration is kept when the file is missing or invalid.
+func reloadConfig(flags CommandLineFlags) error {
+	if _, err := os.Stat(flags.ConfigFile); err != nil {
+		return fmt.Errorf("config file unavailable: %w", err)
+	}
+
+	newConfig, err := loadConfig(flags)
+	if err != nil {
+		return err
+	}
+
+	oldConfig := currentConfig()
+
+	// Keep the original start time so a reload does not restart warmup
+	newConfig.startTime = oldConfig.startTime
+
+	// Settings bound at startup cannot be changed without a restart
+	if newConfig.Server.Port != oldConfig.Server.Port {
+		logWarning("server.port change requires a restart, keeping %s", oldConfig.Server.Port)
+		newConfig.Server.Port = oldConfig.Server.Port
+	}
+	if newConfig.Agent.Enabled != oldConfig.Agent.Enabled || newConfig.Agent.Port != oldConfig.Agent.Port {
+		logWarning("agent.enabled and agent.port changes require a restart, keeping %v and %s", oldConfig.Agent.Enabled, oldConfig.Agent.Port)
+		newConfig.Agent.Enabled = oldConfig.Agent.Enabled
+		newConfig.Agent.Port = oldConfig.Agent.Port
+	}
+	if newConfig.Logging.File != oldConfig.Logging.File {
+		logWarning("logging.file change requires a restart, keeping %s", oldConfig.Logging.File)
+		newConfig.Logging.File = oldConfig.Logging.File
+	}
+	if newConfig.Paths != oldConfig.Paths {
+		// Counters cached from the old roots would produce meaningless deltas
+		logWarning("paths changes require a restart, keeping %s and %s", oldConfig.Paths.Proc, oldConfig.Paths.Sys)
+		newConfig.Paths = oldConfig.Paths
+	}
+	if newConfig.Display != oldConfig.Display {
+		logWarning("display settings changes require a restart, keeping the current ones")
+		newConfig.Display = oldConfig.Display
+	}
+
+	setConfig(newConfig)
+
+	logInfo("Configuration reloaded from %s", flags.ConfigFile)
+	logInfo("Monitoring disk paths: %v", newConfig.Monitoring.DiskPaths)
+	logInfo("Monitoring network interfaces: %v", newConfig.Monitoring.NetworkInterfaces)
+	logInfo("Monitoring ports: %v", newConfig.Monitoring.Ports)
+	logInfo("Active checks: %v", newConfig.Checks)
+
+	return nil
+}
+
+// watchConfig reloads the configuration on SIGHUP and, when reload.watch
+// is enabled, whenever the configuration file changes on disk.
+// Checks added by a reload are registered with registry.
+func watchConfig(ctx context.Context, flags CommandLineFlags, registry *Registry) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	lastModTime := configModTime(flags.ConfigFile)
+
+	for {
+		// Poll the file only when watching is enabled
+		var poll <-chan time.Time
+		cfg := currentConfig()
+		if cfg.Reload.Watch {
+			poll = time.After(cfg.Reload.Interval)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+			logInfo("Received SIGHUP, reloading configuration")
+		case <-poll:
+			modTime := configModTime(flags.ConfigFile)
+			if modTime.Equal(lastModTime) {
+				continue
+			}
+			logInfo("Configuration file %s changed, reloading", flags.ConfigFile)
+		}
+
+		lastModTime = configModTime(flags.ConfigFile)
+		if err := reloadConfig(flags); err != nil {
+			logError("Failed to reload configuration, keeping current one: %v", err)
+			continue
+		}
+		registerChecks(registry, currentConfig().Checks)
+	}
+}
+
+// configModTime returns the modification time of the config file, or zero if it cannot be read
+func configModTime(filename string) time.Time {
+	info, err := os.Stat(filename)
+	if err != nil {
+		return time.Time{}
+	}
+	return info.ModTime()
+}
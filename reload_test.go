@@ -0,0 +1,77 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestReloadConfig(t *testing.T) {
+	oldConfig := config
+	defer func() { config = oldConfig }()
+
+	configFile := filepath.Join(t.TempDir(), "probe-config.yaml")
+	flags := CommandLineFlags{ConfigFile: configFile}
+
+	startTime := time.Now().Add(-time.Hour)
+	config = getDefaultConfig()
+	config.startTime = startTime
+
+	// Valid file replaces thresholds and monitored paths
+	valid := []byte("server:\n  port: \":9999\"\nthresholds:\n  max_cpu: 42\nmonitoring:\n  disk_paths: [\"/\"]\npaths:\n  proc: /host/proc\n" +
+		"agent:\n  enabled: true\n  port: \":9998\"\n  min_weight: 20\ndisplay:\n  enabled: true\n")
+	if err := os.WriteFile(configFile, valid, 0644); err != nil {
+		t.Fatalf("failed to write config file: %v", err)
+	}
+	if err := reloadConfig(flags); err != nil {
+		t.Fatalf("reloadConfig() returned error: %v", err)
+	}
+
+	cfg := currentConfig()
+	if cfg.Thresholds.MaxCPU != 42 {
+		t.Errorf("MaxCPU = %v, want 42", cfg.Thresholds.MaxCPU)
+	}
+	if len(cfg.Monitoring.DiskPaths) != 1 || cfg.Monitoring.DiskPaths[0].Path != "/" {
+		t.Errorf("DiskPaths = %v, want [/]", cfg.Monitoring.DiskPaths)
+	}
+	if !cfg.startTime.Equal(startTime) {
+		t.Errorf("startTime = %v, want %v (warmup must not restart)", cfg.startTime, startTime)
+	}
+	if cfg.Server.Port != ":8080" {
+		t.Errorf("Server.Port = %v, want :8080 (requires restart)", cfg.Server.Port)
+	}
+	if cfg.Paths.Proc != "/proc" {
+		t.Errorf("Paths.Proc = %v, want /proc (requires restart)", cfg.Paths.Proc)
+	}
+	if cfg.Agent.Enabled || cfg.Agent.Port != ":8081" {
+		t.Errorf("Agent enabled = %v, port = %v, want false and :8081 (requires restart)", cfg.Agent.Enabled, cfg.Agent.Port)
+	}
+	if cfg.Agent.MinWeight != 20 {
+		t.Errorf("Agent.MinWeight = %v, want 20", cfg.Agent.MinWeight)
+	}
+	if cfg.Display.Enabled {
+		t.Error("Display.Enabled = true, want false (requires restart)")
+	}
+
+	// Invalid file keeps the current configuration
+	invalid := []byte("thresholds:\n  max_cpu: -1\n")
+	if err := os.WriteFile(configFile, invalid, 0644); err != nil {
+		t.Fatalf("failed to write config file: %v", err)
+	}
+	if err := reloadConfig(flags); err == nil {
+		t.Error("reloadConfig() with invalid config returned nil error")
+	}
+	if cfg := currentConfig(); cfg.Thresholds.MaxCPU != 42 {
+		t.Errorf("MaxCPU after invalid reload = %v, want 42", cfg.Thresholds.MaxCPU)
+	}
+
+	// Missing file keeps the current configuration
+	os.Remove(configFile)
+	if err := reloadConfig(flags); err == nil {
+		t.Error("reloadConfig() with missing file returned nil error")
+	}
+	if cfg := currentConfig(); cfg.Thresholds.MaxCPU != 42 {
+		t.Errorf("MaxCPU after missing file = %v, want 42", cfg.Thresholds.MaxCPU)
+	}
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"os"
+	"time"
+)
+
+// shutdown stops the probe after a SIGTERM or SIGINT. /health and the agent
+// report KO for shutdown.drain_period so the load balancer takes the node out
+// of rotation, then the HTTP server and agent listener stop accepting
+// connections, in-flight requests and collections get up to shutdown.timeout
+// to finish, and the collectors stop. Another signal on signals during the
+// drain period skips its remainder.
+func shutdown(sig os.Signal, signals <-chan os.Signal, server *http.Server, agentListener net.Listener,
+	registry *Registry, stopCollectors context.CancelFunc) {
+	cfg := currentConfig()
+
+	beginShutdown(sig.String())
+	logInfo("Received %s, reporting KO for %v before stopping", sig, cfg.Shutdown.DrainPeriod)
+
+	select {
+	case <-time.After(cfg.Shutdown.DrainPeriod):
+	case sig := <-signals:
+		logWarning("Received %s during the drain period, stopping now", sig)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.Shutdown.Timeout)
+	defer cancel()
+
+	if agentListener != nil {
+		agentListener.Close()
+	}
+	if err := server.Shutdown(ctx); err != nil {
+		logError("HTTP server did not stop cleanly: %v", err)
+	}
+
+	stopCollectors()
+	stopped := make(chan struct{})
+	go func() {
+		registry.Wait()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-ctx.Done():
+		logWarning("Collectors still running after %v, exiting anyway", cfg.Shutdown.Timeout)
+	}
+
+	logInfo("Probe stopped")
+}
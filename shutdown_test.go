@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// setupShutdownTest starts a registry, an HTTP server answering /health and an
+// agent listener, and returns them with the function stopping the collectors
+func setupShutdownTest(t *testing.T, drainPeriod time.Duration) (*Registry, context.Context, context.CancelFunc, *http.Server, net.Listener, net.Listener) {
+	oldConfig := config
+	config = getDefaultConfig()
+	config.Admin.StateFile = ""
+	config.Shutdown.DrainPeriod = drainPeriod
+	config.Shutdown.Timeout = time.Second
+	t.Cleanup(func() {
+		config = oldConfig
+		adminMutex.Lock()
+		shutdownState = nil
+		adminMutex.Unlock()
+	})
+
+	cacheMutex.Lock()
+	metricCache = make(map[string]MetricStatus)
+	cacheMutex.Unlock()
+
+	ctx, stopCollectors := context.WithCancel(context.Background())
+	t.Cleanup(stopCollectors)
+	registry := newRegistry()
+	registry.Register(&fakeCollector{samples: []Sample{{Name: "fake", Value: 1, Max: 2}}})
+	registry.Start(ctx)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("net.Listen() returned error: %v", err)
+	}
+	server := &http.Server{Handler: http.HandlerFunc(healthHandler)}
+	go server.Serve(listener)
+	t.Cleanup(func() { server.Close() })
+
+	agentListener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("net.Listen() returned error: %v", err)
+	}
+	go serveAgent(agentListener)
+	t.Cleanup(func() { agentListener.Close() })
+
+	return registry, ctx, stopCollectors, server, listener, agentListener
+}
+
+func TestShutdownDrainsBeforeStopping(t *testing.T) {
+	registry, ctx, stopCollectors, server, listener, agentListener := setupShutdownTest(t, 200*time.Millisecond)
+
+	done := make(chan struct{})
+	go func() {
+		shutdown(syscall.SIGTERM, make(chan os.Signal), server, agentListener, registry, stopCollectors)
+		close(done)
+	}()
+
+	// During the drain period /health is KO while the server still answers
+	deadline := time.Now().Add(time.Second)
+	for currentAdminState() == nil {
+		if time.Now().After(deadline) {
+			t.Fatal("shutdown mode not set")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	resp, err := http.Get("http://" + listener.Addr().String() + "/health")
+	if err != nil {
+		t.Fatalf("GET /health during drain returned error: %v", err)
+	}
+	var health HealthResponse
+	err = json.NewDecoder(resp.Body).Decode(&health)
+	resp.Body.Close()
+	if err != nil {
+		t.Fatalf("decoding /health returned error: %v", err)
+	}
+	if resp.StatusCode != http.StatusServiceUnavailable || health.Status != "KO" {
+		t.Errorf("/health during drain = %d %s, want 503 KO", resp.StatusCode, health.Status)
+	}
+	if health.Admin == nil || health.Admin.Mode != adminModeShutdown || health.Admin.Reason != "terminated" {
+		t.Errorf("/health admin = %+v, want shutdown mode with reason terminated", health.Admin)
+	}
+
+	conn, err := net.Dial("tcp", agentListener.Addr().String())
+	if err != nil {
+		t.Fatalf("dialing agent during drain returned error: %v", err)
+	}
+	response, _ := io.ReadAll(conn)
+	conn.Close()
+	if string(response) != "drain\n" {
+		t.Errorf("agent response during drain = %q, want %q", response, "drain\n")
+	}
+
+	select {
+	case <-done:
+	case <-time.After(3 * time.Second):
+		t.Fatal("shutdown() did not return")
+	}
+
+	// Everything is stopped once shutdown returns
+	if ctx.Err() == nil {
+		t.Error("collectors context not cancelled")
+	}
+	if _, err := http.Get("http://" + listener.Addr().String() + "/health"); err == nil {
+		t.Error("HTTP server still answering after shutdown")
+	}
+	if _, err := net.Dial("tcp", agentListener.Addr().String()); err == nil {
+		t.Error("agent listener still accepting after shutdown")
+	}
+}
+
+func TestShutdownSecondSignalSkipsDrain(t *testing.T) {
+	registry, _, stopCollectors, server, _, agentListener := setupShutdownTest(t, time.Hour)
+
+	signals := make(chan os.Signal, 1)
+	signals <- syscall.SIGINT
+
+	done := make(chan struct{})
+	go func() {
+		shutdown(syscall.SIGTERM, signals, server, agentListener, registry, stopCollectors)
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(3 * time.Second):
+		t.Fatal("shutdown() still draining after a second signal")
+	}
+}
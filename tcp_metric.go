@@ -0,0 +1,171 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// tcpStateNames maps the st column of /proc/net/tcp to state names
+var tcpStateNames = map[uint64]string{
+	0x01: "established",
+	0x02: "syn_sent",
+	0x03: "syn_recv",
+	0x04: "fin_wait1",
+	0x05: "fin_wait2",
+	0x06: "time_wait",
+	0x07: "close",
+	0x08: "close_wait",
+	0x09: "last_ack",
+	0x0A: "listen",
+	0x0B: "closing",
+	0x0C: "new_syn_recv",
+}
+
+// tcpSocket holds the fields of a /proc/net/tcp entry used by the probe
+type tcpSocket struct {
+	localPort uint64
+	state     string
+	txQueue   uint64
+	rxQueue   uint64
+}
+
+// parseTCPTable parses the content of /proc/net/tcp or /proc/net/tcp6.
+// Format: sl local_address rem_address st tx_queue:rx_queue ...
+func parseTCPTable(data string) ([]tcpSocket, error) {
+	var sockets []tcpSocket
+
+	for i, line := range strings.Split(data, "\n") {
+		fields := strings.Fields(line)
+
+		// Skip header and blank lines
+		if i == 0 || len(fields) == 0 {
+			continue
+		}
+		if len(fields) < 5 {
+			return nil, fmt.Errorf("line %d: expected at least 5 fields, got %d", i+1, len(fields))
+		}
+
+		// Local address is IP:PORT with the port in hex
+		colon := strings.LastIndexByte(fields[1], ':')
+		if colon < 0 {
+			return nil, fmt.Errorf("line %d: invalid local address %q", i+1, fields[1])
+		}
+		port, err := strconv.ParseUint(fields[1][colon+1:], 16, 16)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid local port: %w", i+1, err)
+		}
+
+		state, err := strconv.ParseUint(fields[3], 16, 8)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid state: %w", i+1, err)
+		}
+		stateName, known := tcpStateNames[state]
+		if !known {
+			stateName = fmt.Sprintf("unknown_%02x", state)
+		}
+
+		txHex, rxHex, found := strings.Cut(fields[4], ":")
+		if !found {
+			return nil, fmt.Errorf("line %d: invalid queue field %q", i+1, fields[4])
+		}
+		txQueue, err := strconv.ParseUint(txHex, 16, 64)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid tx_queue: %w", i+1, err)
+		}
+		rxQueue, err := strconv.ParseUint(rxHex, 16, 64)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid rx_queue: %w", i+1, err)
+		}
+
+		sockets = append(sockets, tcpSocket{
+			localPort: port,
+			state:     stateName,
+			txQueue:   txQueue,
+			rxQueue:   rxQueue,
+		})
+	}
+
+	return sockets, nil
+}
+
+// readTCPSockets reads IPv4 and, when available, IPv6 TCP sockets
+func readTCPSockets() ([]tcpSocket, error) {
+	path := procPath("net", "tcp")
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	sockets, err := parseTCPTable(string(data))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	// IPv6 may be disabled
+	path6 := procPath("net", "tcp6")
+	data6, err := os.ReadFile(path6)
+	if errors.Is(err, os.ErrNotExist) {
+		return sockets, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	sockets6, err := parseTCPTable(string(data6))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path6, err)
+	}
+
+	return append(sockets, sockets6...), nil
+}
+
+// countTCPStates counts sockets per state, including every known state with zero
+func countTCPStates(sockets []tcpSocket) map[string]float64 {
+	counts := make(map[string]float64, len(tcpStateNames))
+	for _, name := range tcpStateNames {
+		counts[name] = 0
+	}
+	for _, socket := range sockets {
+		counts[socket.state]++
+	}
+	return counts
+}
+
+// portCounts holds the TCP load of a single local port
+type portCounts struct {
+	Established float64 // Established connections
+	SynRecv     float64 // Half-open connections waiting for the handshake to complete
+	AcceptQueue float64 // Connections waiting in the accept queue of listening sockets
+}
+
+// countPortSockets attributes sockets bound to a local port.
+// For LISTEN sockets, rx_queue holds the current accept queue length.
+func countPortSockets(sockets []tcpSocket, port uint16) portCounts {
+	var counts portCounts
+	for _, socket := range sockets {
+		if socket.localPort != uint64(port) {
+			continue
+		}
+
+		switch socket.state {
+		case "established":
+			counts.Established++
+		case "syn_recv", "new_syn_recv":
+			counts.SynRecv++
+		case "listen":
+			counts.AcceptQueue += float64(socket.rxQueue)
+		}
+	}
+	return counts
+}
+
+// isTCPStateName reports whether name is a known TCP state name
+func isTCPStateName(name string) bool {
+	for _, state := range tcpStateNames {
+		if state == name {
+			return true
+		}
+	}
+	return false
+}
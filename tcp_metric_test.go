@@ -0,0 +1,114 @@
+package main
+
+import (
+	"testing"
+)
+
+// tcpTableFixture is a /proc/net/tcp excerpt where addresses contain " 01 "-like patterns
+const tcpTableFixture = `  sl  local_address rem_address   st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode
+   0: 00000000:0050 00000000:0000 0A 00000000:00000003 00:00000000 00000000     0        0 662 1 0000000000000000 100 0 0 10 0
+   1: 0100007F:0050 0100007F:C350 01 00000000:00000000 00:00000000 00000000     0        0 912 1 0000000000000000 20 4 30 10 -1
+   2: 0100007F:0050 0100007F:C351 08 00000000:00000000 00:00000000 00000000     0        0 913 1 0000000000000000 20 4 30 10 -1
+   3: 0100007F:0050 0100007F:C352 08 00000000:00000000 00:00000000 00000000     0        0 914 1 0000000000000000 20 4 30 10 -1
+   4: 0100007F:C350 0100007F:0050 06 00000000:00000000 03:00001770 00000000     0        0 0 3 0000000000000000
+   5: 0100007F:01BB 01000001:0101 03 00000000:00000000 01:00000064 00000000     0        0 0 2 0000000000000000
+`
+
+func TestParseTCPTable(t *testing.T) {
+	sockets, err := parseTCPTable(tcpTableFixture)
+	if err != nil {
+		t.Fatalf("parseTCPTable() returned error: %v", err)
+	}
+
+	if len(sockets) != 6 {
+		t.Fatalf("parseTCPTable() returned %d sockets, want 6", len(sockets))
+	}
+
+	listen := sockets[0]
+	if listen.state != "listen" || listen.localPort != 80 || listen.rxQueue != 3 {
+		t.Errorf("listen socket = %+v, want listen on port 80 with rx_queue 3", listen)
+	}
+
+	synRecv := sockets[5]
+	if synRecv.state != "syn_recv" || synRecv.localPort != 443 {
+		t.Errorf("syn_recv socket = %+v, want syn_recv on port 443", synRecv)
+	}
+}
+
+func TestParseTCPTableInvalid(t *testing.T) {
+	data := "  sl  local_address rem_address   st tx_queue rx_queue\n   0: 00000000 00000000:0000 0A 00000000:00000000\n"
+	if _, err := parseTCPTable(data); err == nil {
+		t.Error("parseTCPTable() with invalid address returned nil error")
+	}
+}
+
+func TestCountTCPStates(t *testing.T) {
+	sockets, err := parseTCPTable(tcpTableFixture)
+	if err != nil {
+		t.Fatalf("parseTCPTable() returned error: %v", err)
+	}
+
+	counts := countTCPStates(sockets)
+
+	want := map[string]float64{
+		"established": 1,
+		"listen":      1,
+		"close_wait":  2,
+		"time_wait":   1,
+		"syn_recv":    1,
+		"fin_wait1":   0,
+		"last_ack":    0,
+	}
+	for state, wantCount := range want {
+		if counts[state] != wantCount {
+			t.Errorf("count[%s] = %v, want %v", state, counts[state], wantCount)
+		}
+	}
+}
+
+func TestCountPortSockets(t *testing.T) {
+	sockets, err := parseTCPTable(tcpTableFixture)
+	if err != nil {
+		t.Fatalf("parseTCPTable() returned error: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		port uint16
+		want portCounts
+	}{
+		{
+			name: "http port",
+			port: 80,
+			want: portCounts{Established: 1, SynRecv: 0, AcceptQueue: 3},
+		},
+		{
+			name: "https port",
+			port: 443,
+			want: portCounts{Established: 0, SynRecv: 1, AcceptQueue: 0},
+		},
+		{
+			name: "unused port",
+			port: 8080,
+			want: portCounts{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := countPortSockets(sockets, tt.port)
+			if got != tt.want {
+				t.Errorf("countPortSockets(%d) = %+v, want %+v", tt.port, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsTCPStateName(t *testing.T) {
+	if !isTCPStateName("close_wait") {
+		t.Error("isTCPStateName(close_wait) = false, want true")
+	}
+	if isTCPStateName("CLOSE_WAIT") {
+		t.Error("isTCPStateName(CLOSE_WAIT) = true, want false")
+	}
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// Policies for metrics whose status is UNKNOWN
+const (
+	unknownPolicyOK     = "ok"     // UNKNOWN does not affect the overall status
+	unknownPolicyKO     = "ko"     // UNKNOWN makes the probe KO
+	unknownPolicyIgnore = "ignore" // The last known reading and status are kept
+)
+
+// isUnknownPolicy reports whether policy is a valid unknown policy.
+// An empty policy falls back to the global one.
+func isUnknownPolicy(policy string) bool {
+	switch policy {
+	case "", unknownPolicyOK, unknownPolicyKO, unknownPolicyIgnore:
+		return true
+	}
+	return false
+}
+
+// unknownPolicy returns the unknown policy of a metric.
+// A policy for the cache key takes precedence over the metric family,
+// which takes precedence over the global policy.
+func unknownPolicy(name, metric string) string {
+	cfg := currentConfig()
+	policy := cfg.Unknown.Policy
+
+	for _, key := range []string{metric, name} {
+		if override := cfg.Unknown.Metrics[key]; override != "" {
+			policy = override
+		}
+	}
+
+	if policy == "" {
+		policy = unknownPolicyKO
+	}
+	return policy
+}
+
+// applyUnknownPolicy resolves an UNKNOWN metric against its policy. Under the
+// ignore policy the previous reading is kept, with the error attached, unless
+// the metric has never been read.
+func applyUnknownPolicy(metric, previous MetricStatus, exists bool, policy string) MetricStatus {
+	if policy == unknownPolicyIgnore && exists && previous.Status != "UNKNOWN" {
+		previous.Error = metric.Error
+		return previous
+	}
+
+	metric.unknownKO = policy == unknownPolicyKO
+	return metric
+}
+
+// markUncollected reports a collector that has completed no collection since it was
+// registered as an UNKNOWN metric named after it, once staleAfter intervals have passed
+func markUncollected(name string, registered time.Time, interval time.Duration, staleAfter int, now time.Time) bool {
+	age := now.Sub(registered)
+	if staleAfter <= 0 || age <= time.Duration(staleAfter)*interval {
+		return false
+	}
+
+	metric := MetricStatus{
+		Status: "UNKNOWN",
+		Error:  fmt.Sprintf("no collection completed in %s", age.Round(time.Second)),
+		metric: name,
+	}
+
+	cacheMutex.Lock()
+	defer cacheMutex.Unlock()
+	metricCache[name] = applyUnknownPolicy(metric, MetricStatus{}, false, unknownPolicy(name, name))
+	return true
+}
+
+// markStaleMetrics turns cached metrics into UNKNOWN when they have not been
+// updated for staleAfter intervals of the collector that published them
+func markStaleMetrics(names map[string]bool, updated time.Time, interval time.Duration, staleAfter int, now time.Time) {
+	age := now.Sub(updated)
+	if staleAfter <= 0 || age <= time.Duration(staleAfter)*interval {
+		return
+	}
+
+	cacheMutex.Lock()
+	defer cacheMutex.Unlock()
+
+	for name := range names {
+		metric, exists := metricCache[name]
+		if !exists || metric.Status == "UNKNOWN" {
+			continue
+		}
+		policy := unknownPolicy(name, metric.metric)
+		if policy == unknownPolicyIgnore {
+			continue
+		}
+
+		metricCache[name] = MetricStatus{
+			Max:       metric.Max,
+			Min:       metric.Min,
+			Status:    "UNKNOWN",
+			Error:     fmt.Sprintf("not updated for %s", age.Round(time.Second)),
+			metric:    metric.metric,
+			labels:    metric.labels,
+			noMax:     metric.noMax,
+			unknownKO: policy == unknownPolicyKO,
+			lastKnown: lastKnownStatus(metric),
+		}
+	}
+}
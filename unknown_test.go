@@ -0,0 +1,320 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestUnknownPolicy(t *testing.T) {
+	oldConfig := config
+	defer func() { config = oldConfig }()
+
+	config = Config{}
+	config.Unknown.Metrics = map[string]string{
+		"disk":             unknownPolicyOK,
+		"disk_tmp":         unknownPolicyIgnore,
+		"network_rx_bytes": "",
+	}
+
+	tests := []struct {
+		name   string
+		metric string
+		global string
+		want   string
+	}{
+		{name: "memory", metric: "memory", want: unknownPolicyKO},
+		{name: "memory", metric: "memory", global: unknownPolicyOK, want: unknownPolicyOK},
+		{name: "disk_var", metric: "disk", want: unknownPolicyOK},
+		{name: "disk_tmp", metric: "disk", want: unknownPolicyIgnore},
+		{name: "network_eth0_rx_bytes", metric: "network_rx_bytes", global: unknownPolicyIgnore, want: unknownPolicyIgnore},
+	}
+
+	for _, tt := range tests {
+		config.Unknown.Policy = tt.global
+		if got := unknownPolicy(tt.name, tt.metric); got != tt.want {
+			t.Errorf("unknownPolicy(%q, %q) with global %q = %q, want %q", tt.name, tt.metric, tt.global, got, tt.want)
+		}
+	}
+}
+
+func TestPublishUnknownSamples(t *testing.T) {
+	oldConfig := config
+	defer func() { config = oldConfig }()
+
+	readErr := errors.New("statfs /data: no such file or directory")
+
+	tests := []struct {
+		name        string
+		policy      string
+		previous    *Sample
+		wantStatus  string
+		wantCurrent float64
+		wantOverall string
+	}{
+		{
+			name:        "ko policy fails the probe",
+			policy:      unknownPolicyKO,
+			wantStatus:  "UNKNOWN",
+			wantOverall: "KO",
+		},
+		{
+			name:        "ok policy keeps the probe up",
+			policy:      unknownPolicyOK,
+			previous:    &Sample{Value: 97, Max: 95},
+			wantStatus:  "UNKNOWN",
+			wantOverall: "OK",
+		},
+		{
+			name:        "ignore policy keeps the last reading",
+			policy:      unknownPolicyIgnore,
+			previous:    &Sample{Value: 97, Max: 95},
+			wantStatus:  "KO",
+			wantCurrent: 97,
+			wantOverall: "KO",
+		},
+		{
+			name:        "ignore policy without a previous reading",
+			policy:      unknownPolicyIgnore,
+			wantStatus:  "UNKNOWN",
+			wantOverall: "OK",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config = Config{startTime: time.Now()}
+			config.Unknown.Policy = tt.policy
+
+			cacheMutex.Lock()
+			metricCache = make(map[string]MetricStatus)
+			cacheMutex.Unlock()
+
+			if tt.previous != nil {
+				previous := *tt.previous
+				previous.Name = "disk_data"
+				publishSamples([]Sample{previous})
+			}
+			publishSamples([]Sample{{Name: "disk_data", Value: 0, Max: 95, Err: readErr}})
+
+			metrics := snapshotMetrics()
+			metric := metrics["disk_data"]
+			if metric.Status != tt.wantStatus {
+				t.Errorf("status = %v, want %v", metric.Status, tt.wantStatus)
+			}
+			if metric.Current != tt.wantCurrent {
+				t.Errorf("current = %v, want %v", metric.Current, tt.wantCurrent)
+			}
+			if metric.Error != readErr.Error() {
+				t.Errorf("error = %q, want %q", metric.Error, readErr.Error())
+			}
+			if status := overallStatus(metrics); status != tt.wantOverall {
+				t.Errorf("overallStatus() = %v, want %v", status, tt.wantOverall)
+			}
+		})
+	}
+
+	// A successful reading clears the error
+	publishSamples([]Sample{{Name: "disk_data", Value: 50, Max: 95}})
+	if metric := snapshotMetrics()["disk_data"]; metric.Status != "OK" || metric.Error != "" {
+		t.Errorf("after recovery: status = %v, error = %q, want OK without error", metric.Status, metric.Error)
+	}
+}
+
+func TestRegistryMarksStaleMetrics(t *testing.T) {
+	oldConfig := config
+	defer func() { config = oldConfig }()
+
+	config = Config{startTime: time.Now()}
+	config.Unknown.StaleAfter = 3
+	config.Unknown.Metrics = map[string]string{"fake_ignored": unknownPolicyIgnore}
+
+	cacheMutex.Lock()
+	metricCache = make(map[string]MetricStatus)
+	cacheMutex.Unlock()
+
+	// fakeCollector runs every 10ms
+	registry := newRegistry()
+	collector := &fakeCollector{samples: []Sample{
+		{Name: "fake_usage", Value: 1.0, Max: 2.0},
+		{Name: "fake_ignored", Value: 1.0, Max: 2.0},
+	}}
+	registry.collectOnce(context.Background(), collector)
+	updated := registry.updated[collector]
+
+	// Within three intervals nothing changes
+	registry.markStale(updated.Add(30 * time.Millisecond))
+	if metric := snapshotMetrics()["fake_usage"]; metric.Status != "OK" {
+		t.Fatalf("status within stale_after = %v, want OK", metric.Status)
+	}
+
+	registry.markStale(updated.Add(2 * time.Second))
+	metrics := snapshotMetrics()
+	if metric := metrics["fake_usage"]; metric.Status != "UNKNOWN" || metric.Error != "not updated for 2s" || metric.Max != 2.0 {
+		t.Errorf("fake_usage = %+v, want UNKNOWN not updated for 2s with max 2", metric)
+	}
+	if metric := metrics["fake_ignored"]; metric.Status != "OK" || metric.Current != 1.0 {
+		t.Errorf("fake_ignored = %+v, want last reading kept", metric)
+	}
+	if status := overallStatus(metrics); status != "KO" {
+		t.Errorf("overallStatus() = %v, want KO", status)
+	}
+
+	// The next collection replaces the unknown status
+	registry.collectOnce(context.Background(), collector)
+	if metric := snapshotMetrics()["fake_usage"]; metric.Status != "OK" {
+		t.Errorf("status after collection = %v, want OK", metric.Status)
+	}
+
+	// stale_after 0 disables the check
+	config.Unknown.StaleAfter = 0
+	registry.markStale(time.Now().Add(time.Hour))
+	if metric := snapshotMetrics()["fake_usage"]; metric.Status != "OK" {
+		t.Errorf("status with stale_after 0 = %v, want OK", metric.Status)
+	}
+}
+
+func TestUnthresholdedSourceErrors(t *testing.T) {
+	oldConfig := config
+	defer func() { config = oldConfig }()
+
+	writeFile := func(t *testing.T, path, content string) {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name        string
+		collector   Collector
+		setup       func(t *testing.T)
+		key         string
+		wantOverall string
+	}{
+		{
+			name:      "unreadable pressure file",
+			collector: &psiCollector{},
+			setup: func(t *testing.T) {
+				for _, resource := range psiResources {
+					writeFile(t, filepath.Join(config.Paths.Proc, "pressure", resource), "some garbage\n")
+				}
+			},
+			key:         "psi_io",
+			wantOverall: "OK",
+		},
+		{
+			name:      "unreadable pressure file with a limit",
+			collector: &psiCollector{},
+			setup: func(t *testing.T) {
+				config.Thresholds.MaxPSIIOSome = 20
+				for _, resource := range psiResources {
+					writeFile(t, filepath.Join(config.Paths.Proc, "pressure", resource), "some garbage\n")
+				}
+			},
+			key:         "psi_io",
+			wantOverall: "KO",
+		},
+		{
+			name:        "missing diskstats",
+			collector:   &diskIOCollector{},
+			setup:       func(t *testing.T) {},
+			key:         "diskio_root",
+			wantOverall: "OK",
+		},
+		{
+			name:      "unreadable io.stat",
+			collector: &cgroupCollector{},
+			setup: func(t *testing.T) {
+				config.Cgroup.Enabled = true
+				config.Cgroup.Path = "/probe"
+				dir := filepath.Join(cgroupRoot(), "probe")
+				writeFile(t, filepath.Join(dir, "cpu.stat"), "usage_usec 1000\nnr_periods 0\nnr_throttled 0\n")
+				writeFile(t, filepath.Join(dir, "memory.current"), "1024\n")
+				writeFile(t, filepath.Join(dir, "memory.max"), "4096\n")
+				writeFile(t, filepath.Join(dir, "io.stat"), "8:0 rbytes=garbage\n")
+			},
+			key:         "cgroup_io",
+			wantOverall: "OK",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config = getDefaultConfig()
+			config.Warmup.Enabled = false
+			config.Paths.Proc = t.TempDir()
+			config.Paths.Sys = t.TempDir()
+			config.Monitoring.DiskPaths = []DiskPath{{Path: "/"}}
+			tt.setup(t)
+
+			cacheMutex.Lock()
+			metricCache = make(map[string]MetricStatus)
+			cacheMutex.Unlock()
+			cgroupCacheMutex.Lock()
+			cgroupCache = nil
+			cgroupCacheMutex.Unlock()
+
+			samples, _ := tt.collector.Collect(context.Background())
+			publishSamples(samples)
+
+			metrics := snapshotMetrics()
+			if metric := metrics[tt.key]; metric.Status != "UNKNOWN" {
+				t.Errorf("%s = %+v, want UNKNOWN", tt.key, metric)
+			}
+			if status := overallStatus(metrics); status != tt.wantOverall {
+				t.Errorf("overallStatus() = %v, want %v", status, tt.wantOverall)
+			}
+		})
+	}
+}
+
+func TestRegistryMarksUncollected(t *testing.T) {
+	oldConfig := config
+	defer func() { config = oldConfig }()
+
+	config = Config{startTime: time.Now()}
+	config.Unknown.StaleAfter = 3
+
+	cacheMutex.Lock()
+	metricCache = make(map[string]MetricStatus)
+	cacheMutex.Unlock()
+
+	// A collector failing before its first sample publishes nothing
+	registry := newRegistry()
+	collector := &fakeCollector{err: errors.New("permission denied")}
+	registry.Register(collector)
+	registered := registry.updated[collector]
+	registry.collectOnce(context.Background(), collector)
+
+	registry.markStale(registered.Add(30 * time.Millisecond))
+	if metrics := snapshotMetrics(); len(metrics) != 0 {
+		t.Fatalf("metrics within stale_after = %+v, want none", metrics)
+	}
+
+	registry.markStale(registered.Add(2 * time.Second))
+	metrics := snapshotMetrics()
+	if metric := metrics["fake"]; metric.Status != "UNKNOWN" || metric.Error != "no collection completed in 2s" {
+		t.Errorf("fake = %+v, want UNKNOWN no collection completed in 2s", metric)
+	}
+	if status := overallStatus(metrics); status != "KO" {
+		t.Errorf("overallStatus() = %v, want KO", status)
+	}
+
+	// The first collection replaces the placeholder
+	collector.samples = []Sample{{Name: "fake_usage", Value: 1.0, Max: 2.0}}
+	collector.err = nil
+	registry.collectOnce(context.Background(), collector)
+	metrics = snapshotMetrics()
+	if _, exists := metrics["fake"]; exists {
+		t.Error("placeholder still cached after the first collection")
+	}
+	if status := overallStatus(metrics); status != "OK" {
+		t.Errorf("overallStatus() after collection = %v, want OK", status)
+	}
+}